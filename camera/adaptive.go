@@ -0,0 +1,61 @@
+package camera
+
+import (
+	"math"
+	"ray_tracing/framebuffer"
+	"ray_tracing/vector"
+)
+
+// adaptiveSampling stops sampling a pixel once the relative standard error of
+// its mean luminance drops below threshold, taking between minSamples and
+// maxSamples samples.
+type adaptiveSampling struct {
+	minSamples, maxSamples int
+	threshold              float64
+}
+
+// Luminance under this is compared absolutely, so that black pixels converge too.
+const adaptiveDarkFloor = 0.01
+
+// pixelVariance tracks the running mean and squared deviations (Welford) of
+// the luminance of a pixel's samples, the count lives in Framebuffer.Samples.
+type pixelVariance struct {
+	mean, m2 float64
+}
+
+func (pv *pixelVariance) add(n int, c vector.Color) {
+	x := luminance(c)
+	delta := x - pv.mean
+	pv.mean += delta / float64(n+1)
+	pv.m2 += delta * (x - pv.mean)
+}
+
+// relativeError estimates the relative standard error of the mean over n samples.
+func (pv *pixelVariance) relativeError(n int) float64 {
+	if n < 2 {
+		return math.Inf(1)
+	}
+	variance := pv.m2 / float64(n-1)
+	return math.Sqrt(variance/float64(n)) / math.Max(pv.mean, adaptiveDarkFloor)
+}
+
+// remaining returns how many more samples the pixel may take.
+func (a *adaptiveSampling) remaining(pv *pixelVariance, n int) int {
+	if n >= a.maxSamples || (n >= a.minSamples && pv.relativeError(n) <= a.threshold) {
+		return 0
+	}
+	return a.maxSamples - n
+}
+
+func (a *adaptiveSampling) active(variance []pixelVariance, fb *framebuffer.Framebuffer) bool {
+	for i := range variance {
+		if a.remaining(&variance[i], int(fb.Samples[i])) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func luminance(c vector.Color) float64 {
+	return 0.2126*c[0] + 0.7152*c[1] + 0.0722*c[2]
+}
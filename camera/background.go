@@ -0,0 +1,32 @@
+package camera
+
+import (
+	"ray_tracing/ray"
+	"ray_tracing/vector"
+)
+
+// Background returns the radiance arriving along a ray that hits nothing.
+type Background func(r *ray.Ray) vector.Color
+
+// SkyBackground is the default white to light blue sky.
+var SkyBackground = GradientBackground(vector.Color{1.0, 1.0, 1.0}, vector.Color{0.5, 0.7, 1.0})
+
+// NoBackground is black, the scene is lit by its own emitters only.
+func NoBackground(r *ray.Ray) vector.Color {
+	return vector.Color{0, 0, 0}
+}
+
+func SolidBackground(c vector.Color) Background {
+	return func(r *ray.Ray) vector.Color {
+		return c
+	}
+}
+
+// GradientBackground blends from bottom, straight down, to top, straight up.
+func GradientBackground(bottom, top vector.Color) Background {
+	return func(r *ray.Ray) vector.Color {
+		unitDirection := vector.UnitVector(r.Direction)
+		a := 0.5 * (unitDirection.Y() + 1.0)
+		return bottom.Multiply(1.0 - a).Add(top.Multiply(a))
+	}
+}
@@ -3,26 +3,22 @@ package camera
 import (
 	"bufio"
 	"fmt"
-	"log"
+	"io"
 	"math"
 	"os"
+	"ray_tracing/framebuffer"
 	"ray_tracing/interval"
 	"ray_tracing/ray"
+	"ray_tracing/sampler"
 	"ray_tracing/util"
 	"ray_tracing/vector"
+	"runtime"
 	"strings"
-	"sync"
 	"time"
 
-	"golang.org/x/exp/rand"
-
-	prng "gonum.org/v1/gonum/mathext/prng"
-
 	"ray_tracing/hittable"
 )
 
-var randGen *rand.Rand = rand.New(prng.NewSplitMix64(uint64(time.Now().UnixNano())))
-
 type Camera struct {
 	aspectRatio       float64
 	imageWidth        int
@@ -31,6 +27,9 @@ type Camera struct {
 	pixelZeroLocation vector.Vector
 	pixelDeltaU       vector.Vector
 	pixelDeltaV       vector.Vector
+	// differentialScale shrinks the ray differentials from a pixel to the
+	// spacing of its samples, textures are averaged over that much.
+	differentialScale float64
 
 	samplesPerPixel int
 	maxRayDepth     int
@@ -50,11 +49,33 @@ type Camera struct {
 	defocusDiskU  vector.Vector // Defocus disk horizontal radius
 	defocusDiskV  vector.Vector // Defocus disk vertical radius
 
-	logger *bufio.Writer
+	background    Background
+	lightSampling bool // sample emitters directly at diffuse bounces
+
+	seed        uint64 // every pixel sample derives its random stream from it
+	sampler     sampler.Sampler
+	numWorkers  int
+	tileSize    int
+	tileOrder   TileOrder
+	timeBudget  time.Duration // keep adding samples until it runs out, 0 renders samplesPerPixel once
+	progressive bool
+	adaptive    *adaptiveSampling
+	onPass      PassFunc
+	output      io.Writer // progress and settings are reported here
+	// outputFormat, if set, is the encoding of Render instead of the one of
+	// the filename's extension.
+	outputFormat *framebuffer.Format
+	logger       *bufio.Writer
 }
 
 type CameraOption func(c *Camera) *Camera
 
+// PassFunc receives the image averaged over the first samples per pixel after
+// every progressive pass. fb keeps being updated by the following passes, Clone
+// it to hold on to a pass. A pass cut short by ctx or the time budget is not
+// reported.
+type PassFunc func(pass, samples int, fb *framebuffer.Framebuffer)
+
 func DefaultOption(c *Camera) *Camera {
 	c.aspectRatio = 16.0 / 9.0
 	c.imageWidth = 1000
@@ -70,6 +91,16 @@ func DefaultOption(c *Camera) *Camera {
 
 	c.defocusAngle = 0
 	c.focusDistance = 10
+
+	c.background = SkyBackground
+	c.lightSampling = true
+
+	c.seed = uint64(time.Now().UnixNano())
+	c.sampler = sampler.NewIndependent()
+	c.numWorkers = runtime.NumCPU()
+	c.tileSize = 16
+	c.tileOrder = TileOrderHilbert
+	c.output = os.Stdout
 	return c
 }
 
@@ -125,16 +156,144 @@ func WithFocus(defocusAngle, focusDistance float64) CameraOption {
 	}
 }
 
+// WithBackground sets the radiance of rays that leave the scene, use
+// NoBackground for closed interiors lit only by emissive materials.
+func WithBackground(b Background) CameraOption {
+	return func(c *Camera) *Camera {
+		if b == nil {
+			b = NoBackground
+		}
+		c.background = b
+		return c
+	}
+}
+
+// WithLightSampling toggles next event estimation: at every non-specular
+// bounce a shadow ray is sent towards a light, combined with the scattered ray
+// by multiple importance sampling. Disabling it leaves plain path tracing.
+func WithLightSampling(enabled bool) CameraOption {
+	return func(c *Camera) *Camera {
+		c.lightSampling = enabled
+		return c
+	}
+}
+
+// WithSeed makes renders reproducible: the same seed, scene and settings give
+// the same image bit for bit, whatever the number of workers or tiles.
+func WithSeed(seed uint64) CameraOption {
+	return func(c *Camera) *Camera {
+		c.seed = seed
+		return c
+	}
+}
+
+// WithSampler picks the sequence all pixel, lens, time and scattering draws
+// come from, every worker renders with its own clone of s.
+func WithSampler(s sampler.Sampler) CameraOption {
+	return func(c *Camera) *Camera {
+		if s != nil {
+			c.sampler = s
+		}
+		return c
+	}
+}
+
+func WithWorkers(numWorkers int) CameraOption {
+	return func(c *Camera) *Camera {
+		if numWorkers > 0 {
+			c.numWorkers = numWorkers
+		}
+		return c
+	}
+}
+
+// WithTiles splits the image into size x size pixel tiles, which workers take
+// in the given order.
+func WithTiles(size int, order TileOrder) CameraOption {
+	return func(c *Camera) *Camera {
+		if size > 0 {
+			c.tileSize = size
+		}
+		c.tileOrder = order
+		return c
+	}
+}
+
+// WithTimeBudget renders as many samples per pixel as fit in budget instead of
+// a fixed samplesPerPixel.
+func WithTimeBudget(budget time.Duration) CameraOption {
+	return func(c *Camera) *Camera {
+		c.timeBudget = budget
+		return c
+	}
+}
+
+// WithProgressive renders in passes of 1, 2, 4... samples per pixel over the
+// whole image instead of finishing one pixel at a time, calling onPass (if not
+// nil) after each. A time budget always renders progressively.
+func WithProgressive(onPass PassFunc) CameraOption {
+	return func(c *Camera) *Camera {
+		c.progressive = true
+		c.onPass = onPass
+		return c
+	}
+}
+
+// WithAdaptiveSampling gives every pixel between minSamples and maxSamples
+// samples, stopping once the relative standard error of its mean luminance is
+// below threshold. It renders progressively, samplesPerPixel is not used.
+// Framebuffer.Samples (see framebuffer.SampleHeatmap) shows where the samples went.
+func WithAdaptiveSampling(minSamples, maxSamples int, threshold float64) CameraOption {
+	return func(c *Camera) *Camera {
+		minSamples = max(minSamples, 2)
+		c.adaptive = &adaptiveSampling{
+			minSamples: minSamples,
+			maxSamples: max(maxSamples, minSamples),
+			threshold:  threshold,
+		}
+		return c
+	}
+}
+
+// WithOutputFormat makes Render encode as f whatever the extension of the
+// filename, for the formats no extension selects such as
+// framebuffer.FormatEXRUncompressed and the plain text framebuffer.FormatPPM.
+func WithOutputFormat(f framebuffer.Format) CameraOption {
+	return func(c *Camera) *Camera {
+		c.outputFormat = &f
+		return c
+	}
+}
+
+// WithLogger redirects the settings summary and render progress, pass io.Discard to silence them.
+func WithLogger(w io.Writer) CameraOption {
+	return func(c *Camera) *Camera {
+		c.output = w
+		return c
+	}
+}
+
 func (c *Camera) Info() string {
 	buf := strings.Builder{}
 	buf.WriteString("render settings:\n")
 	buf.WriteString(fmt.Sprintf("- aspect ratio: %.2f\n", c.aspectRatio))
 	buf.WriteString(fmt.Sprintf("- image size: %d x %d\n", c.imageWidth, c.imageHeight))
-	buf.WriteString(fmt.Sprintf("- samples per pixel: %d\n", c.samplesPerPixel))
+	if c.timeBudget > 0 {
+		buf.WriteString(fmt.Sprintf("- time budget: %v\n", c.timeBudget))
+	}
+	if c.adaptive != nil {
+		buf.WriteString(fmt.Sprintf("- adaptive samples per pixel: %d to %d, error %.3f\n", c.adaptive.minSamples, c.adaptive.maxSamples, c.adaptive.threshold))
+	} else if c.timeBudget <= 0 {
+		buf.WriteString(fmt.Sprintf("- samples per pixel: %d\n", c.samplesPerPixel))
+	}
 	buf.WriteString(fmt.Sprintf("- ray depth: %d\n", c.maxRayDepth))
 	buf.WriteString(fmt.Sprintf("- vertical FOV: %.2f\n", c.verticalFieldOfView))
 	buf.WriteString(fmt.Sprintf("- defocus angle: %.2f\n", c.defocusAngle))
 	buf.WriteString(fmt.Sprintf("- focus distance: %.2f\n", c.focusDistance))
+	buf.WriteString(fmt.Sprintf("- seed: %d\n", c.seed))
+	buf.WriteString(fmt.Sprintf("- sampler: %T\n", c.sampler))
+	buf.WriteString(fmt.Sprintf("- workers: %d\n", c.numWorkers))
+	buf.WriteString(fmt.Sprintf("- tiles: %dpx, %v order\n", c.tileSize, c.tileOrder))
 
 	return buf.String()
 }
@@ -177,123 +336,121 @@ func (c *Camera) Init(opts ...CameraOption) {
 			Add(viewportV.Divide(2).Negative())
 	c.pixelZeroLocation = viewPortUpleft.Add(c.pixelDeltaU.Add(c.pixelDeltaV).Multiply(0.5))
 
+	// Many samples per pixel already average the texture, a footprint of the
+	// whole pixel would blur it on top of that.
+	samples := c.samplesPerPixel
+	if c.adaptive != nil {
+		samples = c.adaptive.maxSamples
+	}
+	c.differentialScale = max(0.125, 1/math.Sqrt(float64(max(samples, 1))))
+
 	// Calculate the camera defocus disk basis vectors.
 	defocusRadius := c.focusDistance * math.Tan(util.DegressToRadians(c.defocusAngle/2))
 	c.defocusDiskU = c.u.Multiply(defocusRadius)
 	c.defocusDiskV = c.v.Multiply(defocusRadius)
 
-	c.logger = bufio.NewWriter(os.Stdout)
+	c.logger = bufio.NewWriter(c.output)
 
-	fmt.Printf(c.Info())
-}
-
-type renderUnit struct {
-	k, w int
-	pn   *PixelNode
-}
-
-func (c *Camera) startRenderWorker(wg *sync.WaitGroup, input <-chan renderUnit, counter chan<- bool, world hittable.Hittable) {
-	wg.Add(1)
-	go func() {
-		for pix := range input {
-			pixelColor := vector.Color{0, 0, 0}
-			for sample := 0; sample < c.samplesPerPixel; sample++ {
-				r := c.getRay(pix.w, pix.k)
-				pixelColor = pixelColor.Add(c.rayColor(r, c.maxRayDepth, world)) //performance boost if pointer
-				ray.Put(r)
-			}
-			pix.pn.string = ColorString(&pixelColor, c.samplesPerPixel)
-			counter <- true
-		}
-		wg.Done()
-	}()
+	c.logger.WriteString(c.Info())
+	c.logger.Flush()
 }
 
-func (c *Camera) Render(filename string, world hittable.Hittable, numWorkers uint) {
-	start := time.Now()
+// rayColor returns the radiance arriving along r. bsdfPdf is the density with
+// which the previous bounce picked r, 0 for camera rays and specular bounces,
+// where emitters hit by r count fully.
+func (c *Camera) rayColor(r *ray.Ray, depth int, sc *scene, s sampler.Sampler, bsdfPdf float64) vector.Color {
+	rec := hittable.HitRecord{}
+	// If we've exceeded the ray bounce limit, no more light is gathered.
+	if depth <= 0 {
+		return vector.Color{0, 0, 0}
+	}
 
-	//remove old file
-	err := os.Remove(filename)
-	if err != nil {
-		log.Fatal(err)
+	if !sc.world.Hit(r, interval.Interval{0.001, math.Inf(1)}, &rec) {
+		return c.background(r)
 	}
-	//create output file
-	outFile, err := os.Create(filename)
-	if err != nil {
-		log.Fatal(err)
+	rec.ComputeFootprint(r)
+
+	emitted := vector.Color{0, 0, 0}
+	if e, ok := rec.Material.(hittable.Emitter); ok {
+		emitted = e.Emitted(rec.U, rec.V, rec.Point)
+		// Light sampling at the previous bounce may have found this emitter too.
+		if bsdfPdf > 0 && sc.lights != nil {
+			emitted = emitted.Multiply(powerHeuristic(bsdfPdf, sc.lights.Pdf(r.Origin, r.Direction, r.Time)))
+		}
 	}
 
-	var counter chan bool = make(chan bool, 3)
-	var renderInput chan renderUnit = make(chan renderUnit, 500)
-	wg := sync.WaitGroup{}
-	var buf strings.Builder = strings.Builder{}
-	pixelHead := &PixelNode{"", nil}
-
-	for range make([]int, numWorkers) {
-		c.startRenderWorker(&wg, renderInput, counter, world)
+	if rec.Material == nil {
+		// Surfaces without a material, like mesh faces missing one, absorb everything.
+		return emitted
 	}
-
-	go func(cc chan bool) {
-		cnt := 0
-		for range cc {
-			cnt++
-			if cnt%100 == 0 {
-				c.logger.WriteString(fmt.Sprintf("\rremaining: %.2f%%", 100.0*float64(cnt)/float64(c.imageHeight*c.imageWidth)))
-				c.logger.Flush()
+	bsdf, ok := rec.Material.(hittable.BSDF)
+	if !ok {
+		// Materials that only know how to scatter count as specular.
+		ok, scattered, attenuation := rec.Material.Scatter(r, &rec, s)
+		if !ok {
+			if scattered != nil {
+				ray.Put(scattered)
 			}
+			return emitted
 		}
-	}(counter)
-
-	cur := pixelHead
-	for i := 0; i < c.imageHeight; i++ {
-		for j := 0; j < c.imageWidth; j++ {
-			renderInput <- renderUnit{i, j, cur}
-			cur.next = &PixelNode{"", nil}
-			cur = cur.next
-		}
+		return emitted.Add(vector.Multiply(attenuation, c.rayColor(scattered, depth-1, sc, s, 0)))
 	}
 
-	close(renderInput)
-	wg.Wait()
-	close(counter)
-
-	link := pixelHead
-	for link != nil {
-		buf.WriteString(link.string)
-		link = link.next
+	bs, ok := bsdf.Sample(r, &rec, s)
+	if !ok {
+		return emitted
+	}
+	// The shadow ray counts as a bounce, so that the depth limit cuts the same
+	// paths with and without light sampling.
+	if !bs.Specular && sc.lights != nil && depth > 1 {
+		emitted = emitted.Add(c.sampleLight(r, &rec, bsdf, sc, s))
 	}
 
-	outFile.WriteString(fmt.Sprintf("P3\n%d %d\n255\n", c.imageWidth, c.imageHeight))
-	outFile.WriteString(buf.String())
-	buf.Reset()
-
-	c.logger.WriteString(fmt.Sprintf("\relapsed: %v\n", time.Since(start)))
-	c.logger.Flush()
+	scattered := ray.Get()
+	scattered.Origin = rec.Point
+	scattered.Direction = bs.Direction
+	scattered.Time = r.Time
+	incoming := c.rayColor(scattered, depth-1, sc, s, bs.Pdf)
+	ray.Put(scattered)
+	return emitted.Add(vector.Multiply(bs.Weight, incoming))
 }
 
-func (c *Camera) rayColor(r *ray.Ray, depth int, world hittable.Hittable) vector.Color {
-	rec := hittable.HitRecord{}
-	// If we've exceeded the ray bounce limit, no more light is gathered.
-	if depth <= 0 {
+// sampleLight estimates the light arriving at rec directly from the emitters
+// of the scene, with a shadow ray towards one of them.
+func (c *Camera) sampleLight(r *ray.Ray, rec *hittable.HitRecord, bsdf hittable.BSDF, sc *scene, s sampler.Sampler) vector.Color {
+	direction := sc.lights.Sample(rec.Point, r.Time, s)
+	lightPdf := sc.lights.Pdf(rec.Point, direction, r.Time)
+	scatteringPdf := bsdf.Pdf(r, rec, direction)
+	if lightPdf <= 0 || scatteringPdf <= 0 {
 		return vector.Color{0, 0, 0}
 	}
 
-	if world.Hit(r, interval.Interval{0.001, math.Inf(1)}, &rec) {
-		if ok, scattered, attenuation := rec.Material.Scatter(r, &rec); ok {
-			return vector.Multiply(attenuation, c.rayColor(scattered, depth-1, world))
-		} else {
-			ray.Put(scattered)
-			return vector.Color{0, 0, 0}
-		}
+	shadow := ray.Ray{Origin: rec.Point, Direction: direction, Time: r.Time}
+	lightRec := hittable.HitRecord{}
+	if !sc.world.Hit(&shadow, interval.Interval{0.001, math.Inf(1)}, &lightRec) {
+		return vector.Color{0, 0, 0}
 	}
-	unitDirection := vector.UnitVector(r.Direction)
-	a := 0.5 * (unitDirection.Y() + 1.0)
-	return vector.Color{1.0, 1.0, 1.0}.
-		Multiply(1.0 - a).
-		Add(vector.Color{0.5, 0.7, 1.0}.Multiply(a))
+	// Whatever emitter the shadow ray reaches first counts, Lights.Pdf covers
+	// every light along the direction, so the weights still add up.
+	e, ok := lightRec.Material.(hittable.Emitter)
+	if !ok {
+		return vector.Color{0, 0, 0}
+	}
+	weight := powerHeuristic(lightPdf, scatteringPdf)
+	return vector.Multiply(bsdf.Eval(r, rec, direction), e.Emitted(lightRec.U, lightRec.V, lightRec.Point)).
+		Multiply(weight / lightPdf)
 }
 
-func (c *Camera) getRay(i, j int) *ray.Ray {
+// powerHeuristic is the multiple importance sampling weight of the strategy
+// with density f against the one with density g.
+func powerHeuristic(f, g float64) float64 {
+	if f <= 0 {
+		return 0
+	}
+	return f * f / (f*f + g*g)
+}
+
+func (c *Camera) getRay(i, j int, s sampler.Sampler) *ray.Ray {
 	// Get a randomly-sampled camera ray for the pixel at location i,j, originating from
 	// the camera defocus disk.
 	pixelCenter := c.pixelZeroLocation.
@@ -301,48 +458,39 @@ func (c *Camera) getRay(i, j int) *ray.Ray {
 		Add(c.pixelDeltaV.Multiply(float64(j)))
 
 		// pixelSample := pixelCenter.Add(c.pixelSampleDisk(1.0))
-	pixelSample := pixelCenter.Add(c.pixelSampleSquare())
-	rayOrigin := c.defocusDiskSample()
+	pixelSample := pixelCenter.Add(c.pixelSampleSquare(s))
+	rayOrigin := c.defocusDiskSample(s)
 
 	ret := ray.Get()
 	ret.Origin = rayOrigin
 	ret.Direction = pixelSample.Add(rayOrigin.Negative())
-	ret.Time = randGen.Float64()
+	ret.Time = s.Get1D()
+
+	// The differentials leave from the same point of the lens.
+	ret.HasDifferentials = true
+	ret.RxOrigin, ret.RyOrigin = rayOrigin, rayOrigin
+	ret.RxDirection = ret.Direction.Add(c.pixelDeltaU.Multiply(c.differentialScale))
+	ret.RyDirection = ret.Direction.Add(c.pixelDeltaV.Multiply(c.differentialScale))
 	return ret
 }
 
-func (c *Camera) pixelSampleSquare() vector.Vector {
-	//mu.Lock()
-	px, py := -0.5+randGen.Float64(), -0.5+randGen.Float64()
-	//mu.Unlock()
+func (c *Camera) pixelSampleSquare(s sampler.Sampler) vector.Vector {
+	u, v := s.Get2D()
+	px, py := -0.5+u, -0.5+v
 	return c.pixelDeltaU.Multiply(px).Add(c.pixelDeltaV.Multiply(py))
 }
 
-func (c *Camera) pixelSampleDisk(radius float64) vector.Vector {
-	p := vector.RandomInUnitDisk().Multiply(radius)
+func (c *Camera) pixelSampleDisk(radius float64, s sampler.Sampler) vector.Vector {
+	p := vector.SampleUnitDisk(s.Get2D()).Multiply(radius)
 	return c.pixelDeltaU.Multiply(p[0]).Add(c.pixelDeltaV.Multiply(p[1]))
 }
 
-func (c *Camera) defocusDiskSample() vector.Point {
+func (c *Camera) defocusDiskSample(s sampler.Sampler) vector.Point {
+	// Draw even without defocus, so the following dimensions stay put.
+	u, v := s.Get2D()
 	if c.defocusAngle <= 0 {
 		return c.center
 	}
-	p := vector.RandomInUnitDisk()
+	p := vector.SampleUnitDisk(u, v)
 	return c.center.Add(c.defocusDiskU.Multiply(p[0])).Add(c.defocusDiskV.Multiply(p[1]))
 }
-
-func ColorString(c *vector.Color, samples int) string {
-
-	scale := 1.0 / float64(samples) //get avg of samples
-	r := util.LinearToGamma(c.X() * scale)
-	g := util.LinearToGamma(c.Y() * scale)
-	b := util.LinearToGamma(c.Z() * scale)
-	intensity := interval.Interval{0.000, 0.999}
-
-	return fmt.Sprintf(
-		"%d %d %d\n",
-		int(intensity.Clamp(r)*float64(256)),
-		int(intensity.Clamp(g)*float64(256)),
-		int(intensity.Clamp(b)*float64(256)),
-	)
-}
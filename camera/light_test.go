@@ -0,0 +1,87 @@
+package camera
+
+import (
+	"context"
+	"math"
+	"ray_tracing/framebuffer"
+	"ray_tracing/hittable"
+	"ray_tracing/vector"
+	"testing"
+)
+
+// smallLightWorld is lit only by a small, bright sphere, the hard case for
+// plain path tracing.
+func smallLightWorld() hittable.Hittable {
+	return hittable.NewWorld(
+		hittable.NewSphere(vector.Point{0, -100.5, -1}, 100, hittable.NewLambertian(vector.Color{0.5, 0.5, 0.5})),
+		hittable.NewSphere(vector.Point{0.3, -0.2, -1.2}, 0.3, hittable.NewLambertian(vector.Color{0.7, 0.3, 0.2})),
+		hittable.NewSphere(vector.Point{-0.4, 0.8, -1}, 0.15, hittable.NewDiffuseLight(vector.Color{40, 40, 40})),
+		hittable.NewSphere(vector.Point{0.6, 0.5, -0.8}, 0.1, hittable.NewDiffuseLight(vector.Color{60, 30, 10})),
+	)
+}
+
+func renderSmallLights(t *testing.T, samples int, lightSampling bool) *framebuffer.Framebuffer {
+	t.Helper()
+	c := testCamera(
+		WithImageWidth(24),
+		WithSamplesPerPixel(samples),
+		WithMaxRayDepth(6),
+		WithBackground(NoBackground),
+		WithLightSampling(lightSampling),
+		WithSeed(3),
+	)
+	fb, err := c.RenderImage(context.Background(), smallLightWorld())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fb
+}
+
+func meanLuminance(fb *framebuffer.Framebuffer) float64 {
+	sum := 0.0
+	for y := 0; y < fb.Height; y++ {
+		for x := 0; x < fb.Width; x++ {
+			sum += luminance(fb.Color(x, y))
+		}
+	}
+	return sum / float64(fb.Width*fb.Height)
+}
+
+// rmsDifference compares the pixels the reference shows as lit surfaces, those
+// covering the lights themselves are as noisy with and without light sampling.
+func rmsDifference(a, reference *framebuffer.Framebuffer) float64 {
+	sum, n := 0.0, 0
+	for y := 0; y < a.Height; y++ {
+		for x := 0; x < a.Width; x++ {
+			want := luminance(reference.Color(x, y))
+			if want > 1 {
+				continue
+			}
+			d := luminance(a.Color(x, y)) - want
+			sum += d * d
+			n++
+		}
+	}
+	return math.Sqrt(sum / float64(n))
+}
+
+func TestLightSamplingMatchesBruteForce(t *testing.T) {
+	if testing.Short() {
+		t.Skip("brute force reference render")
+	}
+	reference := renderSmallLights(t, 4096, false)
+	sampled := renderSmallLights(t, 64, true)
+	unsampled := renderSmallLights(t, 64, false)
+
+	want, got := meanLuminance(reference), meanLuminance(sampled)
+	t.Logf("mean luminance: light sampling %.4f, brute force %.4f", got, want)
+	if math.Abs(got-want) > 0.03*want {
+		t.Fatalf("mean luminance with light sampling %.4f, brute force reference %.4f", got, want)
+	}
+	// At equal sample counts light sampling has to be much closer to the reference.
+	e, eu := rmsDifference(sampled, reference), rmsDifference(unsampled, reference)
+	t.Logf("rms error: light sampling %.4f, plain path tracing %.4f", e, eu)
+	if e > 0.5*eu {
+		t.Fatalf("light sampling error %.4f, plain path tracing %.4f", e, eu)
+	}
+}
@@ -1,6 +0,0 @@
-package camera
-
-type PixelNode struct {
-	string
-	next *PixelNode
-}
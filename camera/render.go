@@ -0,0 +1,226 @@
+package camera
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"ray_tracing/framebuffer"
+	"ray_tracing/hittable"
+	"ray_tracing/ray"
+	"ray_tracing/vector"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// scene is what a render traces rays against.
+type scene struct {
+	world  hittable.Hittable
+	lights *hittable.Lights // nil when emitters are not sampled directly
+}
+
+// startRenderWorker renders the tiles of queues[id], then steals from the
+// other queues. variance is nil unless sampling is adaptive.
+func (c *Camera) startRenderWorker(id int, stop *atomic.Bool, wg *sync.WaitGroup, queues []*tileQueue, done *atomic.Int64, sc *scene, fb *framebuffer.Framebuffer, variance []pixelVariance, samples int) {
+	wg.Add(1)
+	go func() {
+		s := c.sampler.Clone(c.seed)
+		for t, ok := next(queues, id); ok && !stop.Load(); t, ok = next(queues, id) {
+			for j := t.y0; j < t.y1; j++ {
+				for i := t.x0; i < t.x1; i++ {
+					// Only finished samples are recorded, so a cancelled pass
+					// still leaves exact sample counts behind.
+					pixelColor := vector.Color{0, 0, 0}
+					first := int(fb.Samples[j*fb.Width+i])
+					want := samples
+					var pv *pixelVariance
+					if variance != nil {
+						pv = &variance[j*fb.Width+i]
+						want = min(want, c.adaptive.remaining(pv, first))
+					}
+					taken := 0
+					for ; taken < want && !stop.Load(); taken++ {
+						// The sampler only depends on the pixel and sample index, so the result
+						// does not depend on which worker takes the pixel or in which order.
+						s.StartPixelSample(i, j, first+taken)
+						r := c.getRay(i, j, s)
+						sampleColor := c.rayColor(r, c.maxRayDepth, sc, s, 0)
+						pixelColor = pixelColor.Add(sampleColor) //performance boost if pointer
+						if pv != nil {
+							pv.add(first+taken, sampleColor)
+						}
+						ray.Put(r)
+					}
+					fb.AddSamples(i, j, pixelColor, taken)
+				}
+			}
+			done.Add(int64(t.pixels()))
+		}
+		wg.Done()
+	}()
+}
+
+// Render writes the image to filename, the encoding is picked from its extension
+// (see framebuffer.FormatFromFilename) unless set with WithOutputFormat.
+func (c *Camera) Render(filename string, world hittable.Hittable) error {
+	var format framebuffer.Format
+	var err error
+	if c.outputFormat != nil {
+		format = *c.outputFormat
+	} else if format, err = framebuffer.FormatFromFilename(filename); err != nil {
+		return err
+	}
+	// Render before creating the file, a failed render leaves nothing behind.
+	fb, err := c.RenderImage(context.Background(), world)
+	if err != nil {
+		return err
+	}
+
+	outFile, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := framebuffer.Encode(outFile, fb, format); err != nil {
+		outFile.Close()
+		return err
+	}
+	return outFile.Close()
+}
+
+// RenderTo renders world and writes it to w encoded as format.
+func (c *Camera) RenderTo(w io.Writer, format framebuffer.Format, world hittable.Hittable) error {
+	fb, err := c.RenderImage(context.Background(), world)
+	if err != nil {
+		return err
+	}
+	return framebuffer.Encode(w, fb, format)
+}
+
+// RenderImage renders world into a new linear radiance framebuffer.
+//
+// If ctx is cancelled or its deadline passes, rendering stops after the samples
+// in flight and the partial image is returned together with ctx.Err(). Every
+// pixel of it is the average of the samples recorded in Framebuffer.Samples.
+// Running out of the time budget (WithTimeBudget) is not an error.
+func (c *Camera) RenderImage(ctx context.Context, world hittable.Hittable) (*framebuffer.Framebuffer, error) {
+	if c.imageWidth <= 0 || c.imageHeight <= 0 {
+		return nil, errors.New("camera: not initialized, call Init first")
+	}
+	if world == nil {
+		return nil, errors.New("camera: nil world")
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	fb := framebuffer.New(c.imageWidth, c.imageHeight)
+	tiles := makeTiles(c.imageWidth, c.imageHeight, c.tileSize, c.tileOrder)
+
+	passCtx := ctx
+	if c.timeBudget > 0 {
+		var cancel context.CancelFunc
+		passCtx, cancel = context.WithTimeout(ctx, c.timeBudget)
+		defer cancel()
+	}
+
+	sc := &scene{world: world}
+	if c.lightSampling {
+		if lights := hittable.NewLights(world); lights.Len() > 0 {
+			sc.lights = lights
+		}
+	}
+
+	var variance []pixelVariance
+	if c.adaptive != nil {
+		variance = make([]pixelVariance, c.imageWidth*c.imageHeight)
+	}
+
+	total := 0
+	for pass := 1; passCtx.Err() == nil; pass++ {
+		samples := c.passSamples(pass, total)
+		if samples <= 0 || (c.adaptive != nil && pass > 1 && !c.adaptive.active(variance, fb)) {
+			break
+		}
+		c.renderPass(passCtx, sc, fb, variance, tiles, pass, samples)
+		if passCtx.Err() != nil {
+			// The pass may have been cut short, its pixels don't all have the samples.
+			break
+		}
+		total += samples
+		if c.onPass != nil {
+			c.onPass(pass, total, fb)
+		}
+	}
+
+	c.logger.WriteString(fmt.Sprintf("\relapsed: %v\n", time.Since(start)))
+	c.logger.Flush()
+	return fb, ctx.Err()
+}
+
+// Progressive passes double their sample count up to this many, so that
+// previews keep coming and a pass cut short by the time budget stays small.
+const maxPassSamples = 64
+
+// passSamples returns how many samples per pixel the given pass adds, after
+// total were taken by the previous ones. Adaptive sampling starts with its
+// minimum and lets every pixel decide how much of the following passes it takes.
+func (c *Camera) passSamples(pass, total int) int {
+	if c.adaptive != nil && pass == 1 {
+		return c.adaptive.minSamples
+	}
+	if c.timeBudget <= 0 && !c.progressive && c.adaptive == nil {
+		return c.samplesPerPixel - total
+	}
+	samples := maxPassSamples
+	if pass <= 6 {
+		samples = 1 << (pass - 1)
+	}
+	if c.timeBudget > 0 || c.adaptive != nil {
+		return samples
+	}
+	return min(samples, c.samplesPerPixel-total)
+}
+
+// renderPass adds samples to every pixel of fb, unless ctx is done first.
+func (c *Camera) renderPass(ctx context.Context, sc *scene, fb *framebuffer.Framebuffer, variance []pixelVariance, tiles []tile, pass, samples int) {
+	wg := sync.WaitGroup{}
+
+	// Workers poll a flag instead of the context, it is much cheaper per sample.
+	var stop atomic.Bool
+	finished := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			stop.Store(true)
+		case <-finished:
+		}
+	}()
+
+	var done atomic.Int64
+	queues := distributeTiles(tiles, c.numWorkers)
+	for id := range queues {
+		c.startRenderWorker(id, &stop, &wg, queues, &done, sc, fb, variance, samples)
+	}
+
+	reported := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(200 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				c.logger.WriteString(fmt.Sprintf("\rpass %d: %.2f%%", pass, 100.0*float64(done.Load())/float64(c.imageHeight*c.imageWidth)))
+				c.logger.Flush()
+			case <-finished:
+				close(reported)
+				return
+			}
+		}
+	}()
+
+	wg.Wait()
+	close(finished)
+	<-reported
+}
@@ -0,0 +1,300 @@
+package camera
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"ray_tracing/concrand"
+	"ray_tracing/framebuffer"
+	"ray_tracing/hittable"
+	"ray_tracing/texture"
+	"ray_tracing/vector"
+	"sync"
+	"testing"
+	"time"
+)
+
+func testWorld() hittable.Hittable {
+	ground := hittable.Lambertian{Albedo: texture.NewSolidColor(vector.Color{0.5, 0.5, 0.5})}
+	center := hittable.Lambertian{Albedo: texture.NewSolidColor(vector.Color{0.1, 0.2, 0.5})}
+	return hittable.NewWorld(
+		hittable.NewSphere(vector.Point{0, -100.5, -1}, 100, &ground),
+		hittable.NewSphere(vector.Point{0, 0, -1}, 0.5, &center),
+	)
+}
+
+func testCamera(opts ...CameraOption) *Camera {
+	c := &Camera{}
+	c.Init(append([]CameraOption{WithImageWidth(32), WithLogger(io.Discard)}, opts...)...)
+	return c
+}
+
+func TestRenderImageCancelled(t *testing.T) {
+	c := testCamera(WithSamplesPerPixel(1 << 20))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	fb, err := c.RenderImage(ctx, testWorld())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline error, got %v", err)
+	}
+	if fb == nil {
+		t.Fatal("expected a partial framebuffer")
+	}
+	for y := 0; y < fb.Height; y++ {
+		for x := 0; x < fb.Width; x++ {
+			if n := fb.Samples[y*fb.Width+x]; n == 0 && fb.Color(x, y) != (vector.Color{}) {
+				t.Fatalf("unsampled pixel %d,%d is not black", x, y)
+			}
+		}
+	}
+}
+
+func TestRenderImageTimeBudget(t *testing.T) {
+	c := testCamera(WithTimeBudget(100 * time.Millisecond))
+	fb, err := c.RenderImage(context.Background(), testWorld())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, n := range fb.Samples {
+		if n == 0 {
+			t.Fatalf("pixel %d was never sampled", i)
+		}
+	}
+}
+
+func TestRenderImageProgressive(t *testing.T) {
+	var passes []int
+	c := testCamera(
+		WithSamplesPerPixel(10),
+		WithProgressive(func(pass, samples int, fb *framebuffer.Framebuffer) {
+			passes = append(passes, samples)
+			for i, n := range fb.Samples {
+				if int(n) != samples {
+					t.Fatalf("pass %d: pixel %d has %d samples, expected %d", pass, i, n, samples)
+				}
+			}
+		}),
+	)
+	if _, err := c.RenderImage(context.Background(), testWorld()); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(passes) != "[1 3 7 10]" {
+		t.Fatalf("unexpected pass schedule %v", passes)
+	}
+}
+
+func TestRenderImageProgressiveDeadline(t *testing.T) {
+	passes := 0
+	// Large enough that the budget runs out in the middle of a pass.
+	c := testCamera(
+		WithImageWidth(400),
+		WithTimeBudget(200*time.Millisecond),
+		WithProgressive(func(pass, samples int, fb *framebuffer.Framebuffer) {
+			passes++
+			for i, n := range fb.Samples {
+				if int(n) != samples {
+					t.Fatalf("pass %d: pixel %d has %d samples, expected %d", pass, i, n, samples)
+				}
+			}
+		}),
+	)
+	if _, err := c.RenderImage(context.Background(), testWorld()); err != nil {
+		t.Fatal(err)
+	}
+	if passes == 0 {
+		t.Fatal("no pass finished within the budget")
+	}
+}
+
+func TestRenderImageAdaptive(t *testing.T) {
+	c := testCamera(WithAdaptiveSampling(4, 256, 0.02), WithSeed(1))
+	fb, err := c.RenderImage(context.Background(), testWorld())
+	if err != nil {
+		t.Fatal(err)
+	}
+	lo, hi := fb.Samples[0], fb.Samples[0]
+	for i, n := range fb.Samples {
+		if n < 4 || n > 256 {
+			t.Fatalf("pixel %d took %d samples, outside [4, 256]", i, n)
+		}
+		lo, hi = min(lo, n), max(hi, n)
+	}
+	// The flat sky converges long before the diffuse spheres do.
+	if lo == hi {
+		t.Fatalf("every pixel took %d samples", lo)
+	}
+	if heat := framebuffer.SampleHeatmap(fb); heat.Width != fb.Width || heat.Height != fb.Height {
+		t.Fatal("heatmap size does not match the image")
+	}
+}
+
+func TestRenderImageEmissive(t *testing.T) {
+	light := hittable.NewDiffuseLight(vector.Color{4, 2, 1})
+	world := hittable.NewWorld(hittable.NewSphere(vector.Point{0, 0, -1}, 0.5, light))
+	c := testCamera(WithBackground(NoBackground), WithSamplesPerPixel(2))
+	fb, err := c.RenderImage(context.Background(), world)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fb.Color(fb.Width/2, fb.Height/2); got != (vector.Color{4, 2, 1}) {
+		t.Fatalf("light renders as %v", got)
+	}
+	if got := fb.Color(0, 0); got != (vector.Color{}) {
+		t.Fatalf("empty background renders as %v", got)
+	}
+}
+
+func TestRenderImageMissingMaterial(t *testing.T) {
+	positions := []vector.Point{{-1, -1, -1}, {1, -1, -1}, {0, 1, -1}}
+	mesh := hittable.NewTriangleMesh(positions, []uint32{0, 1, 2}, nil)
+	mesh.SetMaterials([]hittable.Material{hittable.NewLambertian(vector.Color{1, 1, 1})}, []uint16{1})
+	c := testCamera(WithSamplesPerPixel(2))
+	fb, err := c.RenderImage(context.Background(), hittable.NewWorld(mesh))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fb.Color(fb.Width/2, fb.Height/2); got != (vector.Color{}) {
+		t.Fatalf("a face without a material renders as %v", got)
+	}
+}
+
+func TestRenderOutputFormat(t *testing.T) {
+	dir := t.TempDir()
+	for _, test := range []struct {
+		opts        []CameraOption
+		compression byte
+	}{
+		{nil, byte(framebuffer.EXRZip)},
+		{[]CameraOption{WithOutputFormat(framebuffer.FormatEXRUncompressed)}, byte(framebuffer.EXRNone)},
+	} {
+		name := filepath.Join(dir, "out.exr")
+		c := testCamera(append([]CameraOption{WithSamplesPerPixel(1)}, test.opts...)...)
+		if err := c.Render(name, testWorld()); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		attribute := []byte("compression\x00compression\x00\x01\x00\x00\x00")
+		i := bytes.Index(data, attribute)
+		if i < 0 || data[i+len(attribute)] != test.compression {
+			t.Errorf("want compression %d in the header", test.compression)
+		}
+	}
+}
+
+// failingWriter accepts limit bytes, then fails.
+type failingWriter struct {
+	limit int
+}
+
+var errWrite = errors.New("disk full")
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if len(p) > w.limit {
+		n := w.limit
+		w.limit = 0
+		return n, errWrite
+	}
+	w.limit -= len(p)
+	return len(p), nil
+}
+
+func TestRenderErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	if _, err := (&Camera{}).RenderImage(context.Background(), testWorld()); err == nil {
+		t.Error("an uninitialized camera rendered")
+	}
+	if err := (&Camera{}).Render(filepath.Join(dir, "a.png"), testWorld()); err == nil {
+		t.Error("an uninitialized camera rendered to a file")
+	}
+	c := testCamera(WithSamplesPerPixel(1))
+	if _, err := c.RenderImage(context.Background(), nil); err == nil {
+		t.Error("rendered a nil world")
+	}
+	if err := c.Render(filepath.Join(dir, "b.png"), nil); err == nil {
+		t.Error("rendered a nil world to a file")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "b.png")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("a failed render left a file behind: %v", err)
+	}
+	if err := c.Render(filepath.Join(dir, "c.bmp"), testWorld()); err == nil {
+		t.Error("rendered to an unsupported extension")
+	}
+	if err := c.Render(filepath.Join(dir, "missing", "d.png"), testWorld()); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("rendering into a missing directory returned %v", err)
+	}
+	if err := c.RenderTo(io.Discard, framebuffer.Format(-1), testWorld()); err == nil {
+		t.Error("rendered to an unknown format")
+	}
+
+	formats := []framebuffer.Format{
+		framebuffer.FormatPPM, framebuffer.FormatPPMBinary, framebuffer.FormatPNG, framebuffer.FormatPFM,
+		framebuffer.FormatHDR, framebuffer.FormatEXR, framebuffer.FormatEXRUncompressed,
+	}
+	for _, f := range formats {
+		for _, limit := range []int{0, 100} {
+			if err := c.RenderTo(&failingWriter{limit: limit}, f, testWorld()); !errors.Is(err, errWrite) {
+				t.Errorf("format %d, writer failing after %d bytes: got %v", f, limit, err)
+			}
+		}
+	}
+}
+
+// TestConcurrentBuildAndRender builds trees and renders them from several
+// goroutines at once, each with its own random stream. Run it with -race to
+// catch shared random state.
+func TestConcurrentBuildAndRender(t *testing.T) {
+	const renders = 4
+	images := make([]*framebuffer.Framebuffer, renders)
+	errs := make([]error, renders)
+	wg := sync.WaitGroup{}
+	for i := 0; i < renders; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			rng := concrand.New(uint64(i))
+			world := hittable.NewWorld()
+			for j := 0; j < 20; j++ {
+				center := vector.Point{rng.Float64()*4 - 2, rng.Float64() - 0.5, -2 - rng.Float64()}
+				world.Append(hittable.NewSphere(center, 0.2, hittable.NewMetal(vector.Color{0.8, 0.8, 0.8}, 0.3)))
+			}
+			world.Append(testWorld())
+			c := testCamera(WithSamplesPerPixel(4), WithWorkers(4), WithSeed(7))
+			images[i], errs[i] = c.RenderImage(context.Background(), world.ToBVHTree(rng))
+		}(i)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("render %d: %v", i, err)
+		}
+	}
+
+	// The same tree rendered concurrently twice with one seed gives the same image.
+	tree := testWorld().(*hittable.Hittables).ToBVHTree(concrand.New(1))
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			c := testCamera(WithSamplesPerPixel(4), WithWorkers(4), WithSeed(7))
+			images[i], errs[i] = c.RenderImage(context.Background(), tree)
+		}(i)
+	}
+	wg.Wait()
+	if errs[0] != nil || errs[1] != nil {
+		t.Fatal(errs[0], errs[1])
+	}
+	for i := range images[0].Pix {
+		if images[0].Pix[i] != images[1].Pix[i] {
+			t.Fatalf("value %d differs between concurrent renders: %v and %v", i, images[0].Pix[i], images[1].Pix[i])
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package camera
+
+import (
+	"context"
+	"ray_tracing/sampler"
+	"slices"
+	"testing"
+)
+
+func TestRenderImageSeedIsReproducible(t *testing.T) {
+	render := func(opts ...CameraOption) []float32 {
+		c := testCamera(append([]CameraOption{WithSamplesPerPixel(4), WithFocus(2, 1)}, opts...)...)
+		fb, err := c.RenderImage(context.Background(), testWorld())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fb.Pix
+	}
+
+	for _, s := range []sampler.Sampler{sampler.NewIndependent(), sampler.NewStratified(4), sampler.NewHalton(), sampler.NewSobol(), sampler.NewBlueNoise()} {
+		reference := render(WithSampler(s), WithSeed(42), WithWorkers(1), WithTiles(16, TileOrderScanline))
+		if !slices.Equal(reference, render(WithSampler(s), WithSeed(42), WithWorkers(5), WithTiles(7, TileOrderHilbert))) {
+			t.Fatalf("%T: same seed rendered differently with other workers and tiles", s)
+		}
+	}
+
+	reference := render(WithSeed(42), WithWorkers(1))
+	if slices.Equal(reference, render(WithSeed(43), WithWorkers(1))) {
+		t.Fatal("different seeds rendered the same image")
+	}
+}
@@ -0,0 +1,190 @@
+package camera
+
+import (
+	"sort"
+	"sync"
+)
+
+// TileOrder decides in which order the tiles of the image are handed out.
+type TileOrder int
+
+const (
+	TileOrderScanline TileOrder = iota // left to right, top to bottom
+	TileOrderSpiral                    // outwards from the center of the image
+	TileOrderHilbert                   // along a Hilbert curve, neighbours stay close in time
+)
+
+func (o TileOrder) String() string {
+	switch o {
+	case TileOrderScanline:
+		return "scanline"
+	case TileOrderSpiral:
+		return "spiral"
+	case TileOrderHilbert:
+		return "hilbert"
+	}
+	return "unknown"
+}
+
+// tile is the pixel rectangle [x0, x1) x [y0, y1).
+type tile struct {
+	x0, y0, x1, y1 int
+}
+
+func (t tile) pixels() int {
+	return (t.x1 - t.x0) * (t.y1 - t.y0)
+}
+
+func makeTiles(width, height, size int, order TileOrder) []tile {
+	cols := (width + size - 1) / size
+	rows := (height + size - 1) / size
+
+	var coords [][2]int
+	switch order {
+	case TileOrderSpiral:
+		coords = spiralCoords(cols, rows)
+	case TileOrderHilbert:
+		coords = hilbertCoords(cols, rows)
+	default:
+		for ty := 0; ty < rows; ty++ {
+			for tx := 0; tx < cols; tx++ {
+				coords = append(coords, [2]int{tx, ty})
+			}
+		}
+	}
+
+	tiles := make([]tile, len(coords))
+	for i, tc := range coords {
+		tiles[i] = tile{
+			x0: tc[0] * size,
+			y0: tc[1] * size,
+			x1: min((tc[0]+1)*size, width),
+			y1: min((tc[1]+1)*size, height),
+		}
+	}
+	return tiles
+}
+
+// spiralCoords walks right, down, left, up with growing legs from the center
+// tile, keeping only the steps that land inside the grid.
+func spiralCoords(cols, rows int) [][2]int {
+	coords := make([][2]int, 0, cols*rows)
+	x, y := (cols-1)/2, (rows-1)/2
+	dirs := [4][2]int{{1, 0}, {0, 1}, {-1, 0}, {0, -1}}
+	visit := func() {
+		if x >= 0 && x < cols && y >= 0 && y < rows {
+			coords = append(coords, [2]int{x, y})
+		}
+	}
+	visit()
+	for leg, d := 1, 0; len(coords) < cols*rows; d++ {
+		for step := 0; step < leg; step++ {
+			x += dirs[d%4][0]
+			y += dirs[d%4][1]
+			visit()
+		}
+		if d%2 == 1 {
+			leg++
+		}
+	}
+	return coords
+}
+
+// hilbertCoords sorts the grid by the position of each tile on a Hilbert curve
+// covering the next power of two square.
+func hilbertCoords(cols, rows int) [][2]int {
+	n := 1
+	for n < cols || n < rows {
+		n *= 2
+	}
+	coords := make([][2]int, 0, cols*rows)
+	keys := make(map[[2]int]int, cols*rows)
+	for ty := 0; ty < rows; ty++ {
+		for tx := 0; tx < cols; tx++ {
+			tc := [2]int{tx, ty}
+			coords = append(coords, tc)
+			keys[tc] = hilbertIndex(n, tx, ty)
+		}
+	}
+	sort.Slice(coords, func(i, j int) bool { return keys[coords[i]] < keys[coords[j]] })
+	return coords
+}
+
+func hilbertIndex(n, x, y int) int {
+	d := 0
+	for s := n / 2; s > 0; s /= 2 {
+		rx, ry := 0, 0
+		if x&s > 0 {
+			rx = 1
+		}
+		if y&s > 0 {
+			ry = 1
+		}
+		d += s * s * ((3 * rx) ^ ry)
+		// Rotate the quadrant so the curve stays continuous.
+		if ry == 0 {
+			if rx == 1 {
+				x = s - 1 - x
+				y = s - 1 - y
+			}
+			x, y = y, x
+		}
+	}
+	return d
+}
+
+// tileQueue is the work queue of one worker. The owner takes tiles from the
+// front, idle workers steal from the back so they stay out of each other's way.
+type tileQueue struct {
+	mu    sync.Mutex
+	tiles []tile
+}
+
+func (q *tileQueue) pop() (tile, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.tiles) == 0 {
+		return tile{}, false
+	}
+	t := q.tiles[0]
+	q.tiles = q.tiles[1:]
+	return t, true
+}
+
+func (q *tileQueue) steal() (tile, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.tiles) == 0 {
+		return tile{}, false
+	}
+	t := q.tiles[len(q.tiles)-1]
+	q.tiles = q.tiles[:len(q.tiles)-1]
+	return t, true
+}
+
+// distributeTiles deals the tiles out round robin, so every worker starts at
+// the beginning of the chosen order.
+func distributeTiles(tiles []tile, workers int) []*tileQueue {
+	queues := make([]*tileQueue, workers)
+	for i := range queues {
+		queues[i] = &tileQueue{}
+	}
+	for i, t := range tiles {
+		q := queues[i%workers]
+		q.tiles = append(q.tiles, t)
+	}
+	return queues
+}
+
+// next returns the next tile for worker id, stealing once its own queue is empty.
+func next(queues []*tileQueue, id int) (tile, bool) {
+	if t, ok := queues[id].pop(); ok {
+		return t, true
+	}
+	for i := 1; i < len(queues); i++ {
+		if t, ok := queues[(id+i)%len(queues)].steal(); ok {
+			return t, true
+		}
+	}
+	return tile{}, false
+}
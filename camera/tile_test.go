@@ -0,0 +1,35 @@
+package camera
+
+import "testing"
+
+func TestMakeTilesCoverImage(t *testing.T) {
+	const width, height = 103, 37
+	for _, order := range []TileOrder{TileOrderScanline, TileOrderSpiral, TileOrderHilbert} {
+		covered := make([]int, width*height)
+		for _, tl := range makeTiles(width, height, 16, order) {
+			for y := tl.y0; y < tl.y1; y++ {
+				for x := tl.x0; x < tl.x1; x++ {
+					covered[y*width+x]++
+				}
+			}
+		}
+		for i, n := range covered {
+			if n != 1 {
+				t.Fatalf("%v order: pixel %d covered %d times", order, i, n)
+			}
+		}
+	}
+}
+
+func TestWorkStealingDrainsQueues(t *testing.T) {
+	tiles := makeTiles(64, 64, 8, TileOrderSpiral)
+	queues := distributeTiles(tiles, 3)
+	taken := 0
+	// Worker 0 alone has to end up with every tile.
+	for _, ok := next(queues, 0); ok; _, ok = next(queues, 0) {
+		taken++
+	}
+	if taken != len(tiles) {
+		t.Fatalf("took %d of %d tiles", taken, len(tiles))
+	}
+}
@@ -1,50 +1,41 @@
+// Package concrand hands out random streams for concurrent rendering.
+//
+// A *rand.Rand must never be shared between goroutines: every worker owns one
+// and reseeds it with Derive for each unit of work, which keeps the streams
+// independent of scheduling and therefore reproducible.
 package concrand
 
 import (
-	"math/rand"
+	"time"
 
-	"github.com/seehuhn/mt19937"
-)
+	"golang.org/x/exp/rand"
 
-var r *ConcRand = NewConcaRand()
+	prng "gonum.org/v1/gonum/mathext/prng"
+)
 
-func Float64() float64 {
-	return <-r.output
+// New returns a stream owned by the caller.
+func New(seed uint64) *rand.Rand {
+	return rand.New(prng.NewSplitMix64(seed))
 }
 
-type ConcRand struct {
-	*rand.Rand
-	output chan float64
+// NewTimeSeeded returns a stream seeded from the clock, for when reproducibility does not matter.
+func NewTimeSeeded() *rand.Rand {
+	return New(uint64(time.Now().UnixNano()))
 }
 
-func NewConcaRand() *ConcRand {
-	ret := &ConcRand{
-		Rand:   rand.New(mt19937.New()),
-		output: make(chan float64, 100000000),
+// Derive mixes keys into seed, giving well separated seeds for neighbouring
+// keys such as pixel coordinates and sample indices.
+func Derive(seed uint64, keys ...uint64) uint64 {
+	h := seed
+	for _, k := range keys {
+		h = mix64(h ^ (k + 0x9e3779b97f4a7c15))
 	}
-	for i := 0; i < cap(ret.output); i++ {
-		ret.output <- ret.Rand.Float64()
-	}
-
-	go func() {
-		for {
-			//if len(ret.output) < cap(ret.output)/2 {
-			//	for i := 0; i < (cap(ret.output) - len(ret.output)); i++ {
-			ret.output <- ret.Rand.Float64()
-
-			//	}
-			//	runtime.Gosched()
-			//	}
-		}
-	}()
-
-	return ret
-}
-
-func GetChan(c *ConcRand) <-chan float64 {
-	return c.output
+	return h
 }
 
-func Get(c *ConcRand) float64 {
-	return <-c.output
+// mix64 is the SplitMix64 finalizer.
+func mix64(z uint64) uint64 {
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
 }
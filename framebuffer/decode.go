@@ -0,0 +1,203 @@
+package framebuffer
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// Decoders refuse images wider or taller than maxDecodedSide or with more
+// than maxDecodedPixels pixels, a header is more likely broken than that large.
+const (
+	maxDecodedSide   = 1 << 16
+	maxDecodedPixels = 1 << 26
+)
+
+// checkDecodedSize rejects image sizes the decoders won't allocate.
+func checkDecodedSize(width, height int) error {
+	if width > maxDecodedSide || height > maxDecodedSide || width*height > maxDecodedPixels {
+		return fmt.Errorf("image of %dx%d pixels is too large", width, height)
+	}
+	return nil
+}
+
+// DecodePFM reads a color (PF) or grayscale (Pf) portable float map. Rows are
+// flipped to the top to bottom order of the Framebuffer.
+func DecodePFM(r io.Reader) (*Framebuffer, error) {
+	br := bufio.NewReader(r)
+	var magic string
+	var width, height int
+	var scale float64
+	if _, err := fmt.Fscan(br, &magic, &width, &height, &scale); err != nil {
+		return nil, fmt.Errorf("framebuffer: pfm header: %w", err)
+	}
+	// A single whitespace character separates the header from the data.
+	if _, err := br.ReadByte(); err != nil {
+		return nil, fmt.Errorf("framebuffer: pfm header: %w", err)
+	}
+	channels := 0
+	switch magic {
+	case "PF":
+		channels = 3
+	case "Pf":
+		channels = 1
+	default:
+		return nil, fmt.Errorf("framebuffer: not a pfm file, magic %q", magic)
+	}
+	if width <= 0 || height <= 0 || scale == 0 {
+		return nil, fmt.Errorf("framebuffer: bad pfm header %d %d %v", width, height, scale)
+	}
+	if err := checkDecodedSize(width, height); err != nil {
+		return nil, fmt.Errorf("framebuffer: pfm: %w", err)
+	}
+	var order binary.ByteOrder = binary.BigEndian
+	if scale < 0 {
+		order = binary.LittleEndian
+	}
+
+	// Pixels are appended as rows arrive, a truncated file fails before the
+	// whole image is allocated.
+	var pix []float32
+	row := make([]byte, 4*channels*width)
+	for y := 0; y < height; y++ {
+		if _, err := io.ReadFull(br, row); err != nil {
+			return nil, fmt.Errorf("framebuffer: pfm data: %w", err)
+		}
+		for x := 0; x < width; x++ {
+			for c := 0; c < 3; c++ {
+				// Grayscale maps repeat their one channel.
+				pix = append(pix, math.Float32frombits(order.Uint32(row[4*(channels*x+min(c, channels-1)):])))
+			}
+		}
+	}
+	fb := fromPix(width, height, pix)
+	for y := 0; y < height/2; y++ {
+		top := fb.Pix[fb.offset(0, y):fb.offset(0, y+1)]
+		bottom := fb.Pix[fb.offset(0, height-1-y):fb.offset(0, height-y)]
+		for i := range top {
+			top[i], bottom[i] = bottom[i], top[i]
+		}
+	}
+	return fb, nil
+}
+
+// fromPix wraps the decoded pixels of a width by height image.
+func fromPix(width, height int, pix []float32) *Framebuffer {
+	return &Framebuffer{Width: width, Height: height, Pix: pix, Samples: make([]uint32, width*height)}
+}
+
+// DecodeHDR reads a Radiance picture with flat or run length encoded RGBE
+// scanlines. Only the standard -Y H +X W orientation is supported.
+func DecodeHDR(r io.Reader) (*Framebuffer, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.ReadString('\n')
+	if err != nil || !strings.HasPrefix(magic, "#?") {
+		return nil, errors.New("framebuffer: not a radiance file")
+	}
+	// Variables come one per line until an empty one.
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("framebuffer: hdr header: %w", err)
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		if format, ok := strings.CutPrefix(line, "FORMAT="); ok && format != "32-bit_rle_rgbe" {
+			return nil, fmt.Errorf("framebuffer: unsupported hdr format %q", format)
+		}
+	}
+	var width, height int
+	resolution, err := br.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("framebuffer: hdr resolution: %w", err)
+	}
+	if _, err := fmt.Sscanf(resolution, "-Y %d +X %d", &height, &width); err != nil || width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("framebuffer: unsupported hdr resolution %q", strings.TrimSpace(resolution))
+	}
+	if err := checkDecodedSize(width, height); err != nil {
+		return nil, fmt.Errorf("framebuffer: hdr: %w", err)
+	}
+
+	var pix []float32
+	rgbe := make([]byte, 4*width)
+	for y := 0; y < height; y++ {
+		if err := readScanline(br, rgbe); err != nil {
+			return nil, fmt.Errorf("framebuffer: hdr scanline %d: %w", y, err)
+		}
+		for x := 0; x < width; x++ {
+			r, g, b := fromRGBE(rgbe[4*x : 4*x+4])
+			pix = append(pix, r, g, b)
+		}
+	}
+	return fromPix(width, height, pix), nil
+}
+
+func fromRGBE(src []byte) (r, g, b float32) {
+	if src[3] == 0 {
+		return 0, 0, 0
+	}
+	// Components are the truncated mantissas, the middle of their step is the best guess.
+	f := math.Ldexp(1, int(src[3])-136)
+	return float32((float64(src[0]) + 0.5) * f), float32((float64(src[1]) + 0.5) * f), float32((float64(src[2]) + 0.5) * f)
+}
+
+// readScanline fills rgbe with one scanline, undoing the run length encoding
+// written by EncodeHDR when the line starts with its marker.
+func readScanline(br *bufio.Reader, rgbe []byte) error {
+	width := len(rgbe) / 4
+	head, err := br.Peek(4)
+	if err != nil {
+		return err
+	}
+	if width < 8 || width > 0x7fff || head[0] != 2 || head[1] != 2 || head[2]&0x80 != 0 {
+		_, err := io.ReadFull(br, rgbe)
+		return err
+	}
+	if int(head[2])<<8|int(head[3]) != width {
+		return errors.New("scanline width mismatch")
+	}
+	br.Discard(4)
+
+	for c := 0; c < 4; c++ {
+		for x := 0; x < width; {
+			count, err := br.ReadByte()
+			if err != nil {
+				return err
+			}
+			if count > 128 {
+				n := int(count) - 128
+				if x+n > width {
+					return errors.New("run overflows the scanline")
+				}
+				value, err := br.ReadByte()
+				if err != nil {
+					return err
+				}
+				for ; n > 0; n-- {
+					rgbe[4*x+c] = value
+					x++
+				}
+				continue
+			}
+			n := int(count)
+			if n == 0 || x+n > width {
+				return errors.New("bad literal length")
+			}
+			for ; n > 0; n-- {
+				value, err := br.ReadByte()
+				if err != nil {
+					return err
+				}
+				rgbe[4*x+c] = value
+				x++
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package framebuffer
+
+import (
+	"bufio"
+	"fmt"
+	"image/png"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+type Format int
+
+const (
+	FormatPPM             Format = iota // ASCII P3
+	FormatPPMBinary                     // binary P6
+	FormatPNG                           // 8-bit PNG
+	FormatPFM                           // float32 portable float map
+	FormatHDR                           // Radiance RGBE
+	FormatEXR                           // OpenEXR, zip compressed float32
+	FormatEXRUncompressed               // OpenEXR, uncompressed float32
+)
+
+// Extensions understood by FormatFromFilename. ".ppm" is binary P6, what most
+// tools expect, the plain text P3 output is only written when asked for with
+// FormatPPM.
+var extensions = map[string]Format{
+	".ppm": FormatPPMBinary,
+	".pnm": FormatPPMBinary,
+	".png": FormatPNG,
+	".pfm": FormatPFM,
+	".hdr": FormatHDR,
+	".exr": FormatEXR,
+}
+
+func FormatFromFilename(filename string) (Format, error) {
+	ext := strings.ToLower(filepath.Ext(filename))
+	f, ok := extensions[ext]
+	if !ok {
+		return 0, fmt.Errorf("framebuffer: unsupported output extension %q", ext)
+	}
+	return f, nil
+}
+
+func Encode(w io.Writer, fb *Framebuffer, f Format) error {
+	switch f {
+	case FormatPPM:
+		return EncodePPM(w, fb)
+	case FormatPPMBinary:
+		return EncodePPMBinary(w, fb)
+	case FormatPNG:
+		return EncodePNG(w, fb)
+	case FormatPFM:
+		return EncodePFM(w, fb)
+	case FormatHDR:
+		return EncodeHDR(w, fb)
+	case FormatEXR:
+		return EncodeEXR(w, fb, EXRZip)
+	case FormatEXRUncompressed:
+		return EncodeEXR(w, fb, EXRNone)
+	}
+	return fmt.Errorf("framebuffer: unknown format %d", f)
+}
+
+func EncodePPM(w io.Writer, fb *Framebuffer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "P3\n%d %d\n255\n", fb.Width, fb.Height)
+	for y := 0; y < fb.Height; y++ {
+		for x := 0; x < fb.Width; x++ {
+			r, g, b := fb.RGB8(x, y)
+			fmt.Fprintf(bw, "%d %d %d\n", r, g, b)
+		}
+	}
+	return bw.Flush()
+}
+
+func EncodePPMBinary(w io.Writer, fb *Framebuffer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "P6\n%d %d\n255\n", fb.Width, fb.Height)
+	for y := 0; y < fb.Height; y++ {
+		for x := 0; x < fb.Width; x++ {
+			r, g, b := fb.RGB8(x, y)
+			bw.Write([]byte{r, g, b})
+		}
+	}
+	return bw.Flush()
+}
+
+func EncodePNG(w io.Writer, fb *Framebuffer) error {
+	return png.Encode(w, fb)
+}
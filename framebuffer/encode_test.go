@@ -0,0 +1,116 @@
+package framebuffer
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"image/png"
+	"io"
+	"ray_tracing/vector"
+	"testing"
+)
+
+// testImage has values below, inside and above the displayable range.
+func testImage(width, height int) *Framebuffer {
+	fb := New(width, height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			fb.SetColor(x, y, vector.Color{float64(x) / float64(width), float64(y) * 0.7, -0.25 + float64(x+y)/4})
+		}
+	}
+	return fb
+}
+
+func TestFormatFromFilename(t *testing.T) {
+	for name, want := range map[string]Format{
+		"a.ppm": FormatPPMBinary,
+		"a.PNG": FormatPNG,
+		"a.exr": FormatEXR,
+		"a.hdr": FormatHDR,
+	} {
+		if got, err := FormatFromFilename(name); err != nil || got != want {
+			t.Errorf("%s: got %v, %v, want %v", name, got, err, want)
+		}
+	}
+	if _, err := FormatFromFilename("a.bmp"); err == nil {
+		t.Error("a.bmp has a format")
+	}
+}
+
+func TestEncodePNG(t *testing.T) {
+	fb := testImage(5, 3)
+	buf := bytes.Buffer{}
+	if err := Encode(&buf, fb, FormatPNG); err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != fb.Width || b.Dy() != fb.Height {
+		t.Fatalf("got bounds %v", b)
+	}
+	for y := 0; y < fb.Height; y++ {
+		for x := 0; x < fb.Width; x++ {
+			r, g, b, a := img.At(x, y).RGBA()
+			wr, wg, wb := fb.RGB8(x, y)
+			if uint8(r>>8) != wr || uint8(g>>8) != wg || uint8(b>>8) != wb || a != 0xffff {
+				t.Errorf("pixel %d, %d is %d %d %d %d, want %d %d %d", x, y, r>>8, g>>8, b>>8, a>>8, wr, wg, wb)
+			}
+		}
+	}
+}
+
+// readPPMHeader reads the magic number, size and maximum value of a PPM file.
+func readPPMHeader(t *testing.T, r *bufio.Reader) string {
+	var magic string
+	var width, height, maxValue int
+	if _, err := fmt.Fscan(r, &magic, &width, &height, &maxValue); err != nil {
+		t.Fatal(err)
+	}
+	if width != 5 || height != 3 || maxValue != 255 {
+		t.Fatalf("got header %s %d %d %d", magic, width, height, maxValue)
+	}
+	return magic
+}
+
+func TestEncodePPM(t *testing.T) {
+	fb := testImage(5, 3)
+
+	buf := bytes.Buffer{}
+	if err := Encode(&buf, fb, FormatPPMBinary); err != nil {
+		t.Fatal(err)
+	}
+	r := bufio.NewReader(&buf)
+	if magic := readPPMHeader(t, r); magic != "P6" {
+		t.Fatalf("got magic %s, want P6", magic)
+	}
+	r.ReadByte()
+	pix, _ := io.ReadAll(r)
+	if len(pix) != 3*fb.Width*fb.Height {
+		t.Fatalf("got %d bytes of pixels", len(pix))
+	}
+	for i := 0; i < fb.Width*fb.Height; i++ {
+		if rr, g, b := fb.RGB8(i%fb.Width, i/fb.Width); pix[3*i] != rr || pix[3*i+1] != g || pix[3*i+2] != b {
+			t.Errorf("pixel %d is %v, want %d %d %d", i, pix[3*i:3*i+3], rr, g, b)
+		}
+	}
+
+	buf.Reset()
+	if err := Encode(&buf, fb, FormatPPM); err != nil {
+		t.Fatal(err)
+	}
+	r = bufio.NewReader(&buf)
+	if magic := readPPMHeader(t, r); magic != "P3" {
+		t.Fatalf("got magic %s, want P3", magic)
+	}
+	for i := 0; i < fb.Width*fb.Height; i++ {
+		var rr, g, b uint8
+		if _, err := fmt.Fscan(r, &rr, &g, &b); err != nil {
+			t.Fatal(err)
+		}
+		if wr, wg, wb := fb.RGB8(i%fb.Width, i/fb.Width); rr != wr || g != wg || b != wb {
+			t.Errorf("pixel %d is %d %d %d, want %d %d %d", i, rr, g, b, wr, wg, wb)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package framebuffer
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+)
+
+type EXRCompression byte
+
+const (
+	EXRNone EXRCompression = 0
+	EXRZip  EXRCompression = 3 // zlib over blocks of 16 scanlines
+)
+
+const (
+	exrMagic      = 20000630
+	exrPixelFloat = 2
+)
+
+func (c EXRCompression) linesPerBlock() int {
+	if c == EXRZip {
+		return 16
+	}
+	return 1
+}
+
+// EncodeEXR writes a single part scanline OpenEXR file with float32 R, G and B channels.
+func EncodeEXR(w io.Writer, fb *Framebuffer, compression EXRCompression) error {
+	// The data window of an empty image would be inverted, which readers reject.
+	if fb.Width <= 0 || fb.Height <= 0 {
+		return errors.New("framebuffer: cannot write an empty image as OpenEXR")
+	}
+	header := &bytes.Buffer{}
+	le := binary.LittleEndian
+
+	binary.Write(header, le, int32(exrMagic))
+	binary.Write(header, le, int32(2)) // version 2, single part scanline
+
+	// Channels have to be listed in alphabetical order.
+	chlist := &bytes.Buffer{}
+	for _, name := range []string{"B", "G", "R"} {
+		chlist.WriteString(name)
+		chlist.WriteByte(0)
+		binary.Write(chlist, le, int32(exrPixelFloat))
+		chlist.Write([]byte{0, 0, 0, 0}) // pLinear + reserved
+		binary.Write(chlist, le, [2]int32{1, 1})
+	}
+	chlist.WriteByte(0)
+
+	window := [4]int32{0, 0, int32(fb.Width - 1), int32(fb.Height - 1)}
+	writeEXRAttribute(header, "channels", "chlist", chlist.Bytes())
+	writeEXRAttribute(header, "compression", "compression", []byte{byte(compression)})
+	writeEXRAttribute(header, "dataWindow", "box2i", window)
+	writeEXRAttribute(header, "displayWindow", "box2i", window)
+	writeEXRAttribute(header, "lineOrder", "lineOrder", []byte{0})
+	writeEXRAttribute(header, "pixelAspectRatio", "float", float32(1))
+	writeEXRAttribute(header, "screenWindowCenter", "v2f", [2]float32{0, 0})
+	writeEXRAttribute(header, "screenWindowWidth", "float", float32(1))
+	header.WriteByte(0)
+
+	lines := compression.linesPerBlock()
+	blockCount := (fb.Height + lines - 1) / lines
+
+	chunks := &bytes.Buffer{}
+	offsets := make([]uint64, blockCount)
+	base := uint64(header.Len() + 8*blockCount)
+	raw := make([]byte, 0, 4*3*fb.Width*lines)
+	for block := 0; block < blockCount; block++ {
+		y0 := block * lines
+		y1 := min(y0+lines, fb.Height)
+
+		// Each scanline holds all the pixels of one channel after the other.
+		raw = raw[:0]
+		for y := y0; y < y1; y++ {
+			for c := 2; c >= 0; c-- {
+				for x := 0; x < fb.Width; x++ {
+					raw = le.AppendUint32(raw, math.Float32bits(fb.Pix[fb.offset(x, y)+c]))
+				}
+			}
+		}
+
+		data := raw
+		if compression == EXRZip {
+			compressed, err := zipEXRBlock(raw)
+			if err != nil {
+				return err
+			}
+			// Readers expect raw data whenever compression does not pay off.
+			if len(compressed) < len(raw) {
+				data = compressed
+			}
+		}
+
+		offsets[block] = base + uint64(chunks.Len())
+		binary.Write(chunks, le, int32(y0))
+		binary.Write(chunks, le, int32(len(data)))
+		chunks.Write(data)
+	}
+
+	binary.Write(header, le, offsets)
+	if _, err := header.WriteTo(w); err != nil {
+		return err
+	}
+	_, err := chunks.WriteTo(w)
+	return err
+}
+
+func writeEXRAttribute(buf *bytes.Buffer, name, typ string, value any) {
+	buf.WriteString(name)
+	buf.WriteByte(0)
+	buf.WriteString(typ)
+	buf.WriteByte(0)
+	if b, ok := value.([]byte); ok {
+		binary.Write(buf, binary.LittleEndian, int32(len(b)))
+		buf.Write(b)
+		return
+	}
+	binary.Write(buf, binary.LittleEndian, int32(binary.Size(value)))
+	binary.Write(buf, binary.LittleEndian, value)
+}
+
+// zipEXRBlock applies the OpenEXR zip preprocessing (byte interleaving
+// followed by a delta predictor) and deflates the result.
+func zipEXRBlock(raw []byte) ([]byte, error) {
+	if len(raw) == 0 {
+		return nil, errors.New("framebuffer: empty OpenEXR block")
+	}
+	tmp := make([]byte, len(raw))
+	half := (len(raw) + 1) / 2
+	for i := range raw {
+		if i%2 == 0 {
+			tmp[i/2] = raw[i]
+		} else {
+			tmp[half+i/2] = raw[i]
+		}
+	}
+	prev := int(tmp[0])
+	for i := 1; i < len(tmp); i++ {
+		cur := int(tmp[i])
+		tmp[i] = byte(cur - prev + 128 + 256)
+		prev = cur
+	}
+
+	out := &bytes.Buffer{}
+	zw := zlib.NewWriter(out)
+	if _, err := zw.Write(tmp); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
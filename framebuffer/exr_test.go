@@ -0,0 +1,143 @@
+package framebuffer
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"io"
+	"math"
+	"testing"
+)
+
+// readEXRHeader returns the attributes of a single part scanline file and the
+// rest of the data, starting at the line offset table.
+func readEXRHeader(t *testing.T, data []byte) (map[string][]byte, []byte) {
+	le := binary.LittleEndian
+	if le.Uint32(data) != exrMagic || le.Uint32(data[4:]) != 2 {
+		t.Fatalf("got magic %d version %d", le.Uint32(data), le.Uint32(data[4:]))
+	}
+	attributes := map[string][]byte{}
+	rest := data[8:]
+	for rest[0] != 0 {
+		name, after, _ := bytes.Cut(rest, []byte{0})
+		typ, after, _ := bytes.Cut(after, []byte{0})
+		size := le.Uint32(after)
+		attributes[string(name)+" "+string(typ)] = after[4 : 4+size]
+		rest = after[4+size:]
+	}
+	return attributes, rest[1:]
+}
+
+// unzipEXRBlock undoes zipEXRBlock.
+func unzipEXRBlock(t *testing.T, data []byte) []byte {
+	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmp, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < len(tmp); i++ {
+		tmp[i] = byte(int(tmp[i-1]) + int(tmp[i]) - 128)
+	}
+	raw := make([]byte, len(tmp))
+	half := (len(tmp) + 1) / 2
+	for i := range raw {
+		if i%2 == 0 {
+			raw[i] = tmp[i/2]
+		} else {
+			raw[i] = tmp[half+i/2]
+		}
+	}
+	return raw
+}
+
+func TestEncodeEXR(t *testing.T) {
+	le := binary.LittleEndian
+	fb := testImage(7, 37)
+	for _, test := range []struct {
+		compression EXRCompression
+		lines       int
+	}{
+		{EXRNone, 1},
+		{EXRZip, 16},
+	} {
+		buf := bytes.Buffer{}
+		if err := EncodeEXR(&buf, fb, test.compression); err != nil {
+			t.Fatal(err)
+		}
+		data := buf.Bytes()
+		attributes, rest := readEXRHeader(t, data)
+
+		if got := attributes["compression compression"]; !bytes.Equal(got, []byte{byte(test.compression)}) {
+			t.Errorf("compression %d: got compression %v", test.compression, got)
+		}
+		window := []byte{}
+		for _, v := range []int32{0, 0, 6, 36} {
+			window = le.AppendUint32(window, uint32(v))
+		}
+		for _, name := range []string{"dataWindow box2i", "displayWindow box2i"} {
+			if !bytes.Equal(attributes[name], window) {
+				t.Errorf("compression %d: got %s %v", test.compression, name, attributes[name])
+			}
+		}
+		if !bytes.Equal(attributes["lineOrder lineOrder"], []byte{0}) {
+			t.Errorf("compression %d: got lineOrder %v", test.compression, attributes["lineOrder lineOrder"])
+		}
+		channels := attributes["channels chlist"]
+		for _, name := range []string{"B", "G", "R"} {
+			if !bytes.HasPrefix(channels, []byte(name+"\x00")) || le.Uint32(channels[2:]) != exrPixelFloat {
+				t.Fatalf("compression %d: got channels %v", test.compression, attributes["channels chlist"])
+			}
+			channels = channels[2+16:]
+		}
+
+		// The offset table points at every block, in order.
+		blocks := (fb.Height + test.lines - 1) / test.lines
+		compressed := 0
+		offsets := rest[:8*blocks]
+		for block := 0; block < blocks; block++ {
+			offset := le.Uint64(offsets[8*block:])
+			if block == 0 && offset != uint64(len(data)-len(rest)+8*blocks) {
+				t.Errorf("compression %d: first block at %d, right after the table is %d", test.compression, offset, len(data)-len(rest)+8*blocks)
+			}
+			chunk := data[offset:]
+			y0 := int(int32(le.Uint32(chunk)))
+			size := le.Uint32(chunk[4:])
+			if y0 != block*test.lines {
+				t.Fatalf("compression %d: block %d starts at line %d", test.compression, block, y0)
+			}
+			lines := min(test.lines, fb.Height-y0)
+			raw := chunk[8 : 8+size]
+			if int(size) < 4*3*fb.Width*lines {
+				raw = unzipEXRBlock(t, raw)
+				compressed++
+			}
+			if len(raw) != 4*3*fb.Width*lines {
+				t.Fatalf("compression %d: block %d holds %d bytes", test.compression, block, len(raw))
+			}
+			for i := 0; i < len(raw)/4; i++ {
+				x, c, y := i%fb.Width, 2-i/fb.Width%3, y0+i/(3*fb.Width)
+				got := math.Float32frombits(le.Uint32(raw[4*i:]))
+				if want := fb.Pix[fb.offset(x, y)+c]; got != want {
+					t.Fatalf("compression %d: channel %d of %d, %d is %v, want %v", test.compression, c, x, y, got, want)
+				}
+			}
+		}
+		if test.compression == EXRZip && compressed == 0 {
+			t.Error("no block was compressed")
+		}
+	}
+}
+
+func TestEncodeEXREmpty(t *testing.T) {
+	for _, fb := range []*Framebuffer{New(0, 4), New(4, 0)} {
+		if err := EncodeEXR(io.Discard, fb, EXRZip); err == nil {
+			t.Errorf("wrote a %d x %d image", fb.Width, fb.Height)
+		}
+	}
+	if _, err := zipEXRBlock(nil); err == nil {
+		t.Error("compressed an empty block")
+	}
+}
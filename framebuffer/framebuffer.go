@@ -0,0 +1,97 @@
+package framebuffer
+
+import (
+	"image"
+	"image/color"
+	"ray_tracing/interval"
+	"ray_tracing/util"
+	"ray_tracing/vector"
+)
+
+// Framebuffer keeps the rendered image in memory as linear float32 RGB triplets,
+// row by row starting from the top left pixel. Values are not clamped, so the
+// full radiance range survives until an encoder decides what to do with it.
+//
+// Samples holds how many samples were averaged into each pixel, an interrupted
+// render leaves some of them lower than others (or zero).
+type Framebuffer struct {
+	Width, Height int
+	Pix           []float32
+	Samples       []uint32
+}
+
+func New(width, height int) *Framebuffer {
+	return &Framebuffer{
+		Width:   width,
+		Height:  height,
+		Pix:     make([]float32, 3*width*height),
+		Samples: make([]uint32, width*height),
+	}
+}
+
+func (fb *Framebuffer) Clone() *Framebuffer {
+	return &Framebuffer{
+		Width:   fb.Width,
+		Height:  fb.Height,
+		Pix:     append([]float32(nil), fb.Pix...),
+		Samples: append([]uint32(nil), fb.Samples...),
+	}
+}
+
+func (fb *Framebuffer) offset(x, y int) int {
+	return 3 * (y*fb.Width + x)
+}
+
+// SetColor stores an averaged linear color.
+func (fb *Framebuffer) SetColor(x, y int, c vector.Color) {
+	o := fb.offset(x, y)
+	fb.Pix[o] = float32(c[0])
+	fb.Pix[o+1] = float32(c[1])
+	fb.Pix[o+2] = float32(c[2])
+}
+
+// AddSamples merges n more samples, whose sum is given, into the running average of a pixel.
+func (fb *Framebuffer) AddSamples(x, y int, sum vector.Color, n int) {
+	if n <= 0 {
+		return
+	}
+	i := y*fb.Width + x
+	prev := float64(fb.Samples[i])
+	total := prev + float64(n)
+	fb.SetColor(x, y, fb.Color(x, y).Multiply(prev).Add(sum).Divide(total))
+	fb.Samples[i] += uint32(n)
+}
+
+func (fb *Framebuffer) Color(x, y int) vector.Color {
+	o := fb.offset(x, y)
+	return vector.Color{float64(fb.Pix[o]), float64(fb.Pix[o+1]), float64(fb.Pix[o+2])}
+}
+
+// RGB8 returns the gamma corrected pixel clamped to 8 bits, as used by the LDR encoders.
+func (fb *Framebuffer) RGB8(x, y int) (r, g, b uint8) {
+	o := fb.offset(x, y)
+	return toByte(fb.Pix[o]), toByte(fb.Pix[o+1]), toByte(fb.Pix[o+2])
+}
+
+func toByte(linear float32) uint8 {
+	intensity := interval.Interval{0.000, 0.999}
+	if linear <= 0 {
+		return 0
+	}
+	return uint8(intensity.Clamp(util.LinearToGamma(float64(linear))) * 256)
+}
+
+// image.Image implementation, so the standard encoders can consume the buffer directly.
+
+func (fb *Framebuffer) ColorModel() color.Model {
+	return color.RGBAModel
+}
+
+func (fb *Framebuffer) Bounds() image.Rectangle {
+	return image.Rect(0, 0, fb.Width, fb.Height)
+}
+
+func (fb *Framebuffer) At(x, y int) color.Color {
+	r, g, b := fb.RGB8(x, y)
+	return color.RGBA{r, g, b, 0xff}
+}
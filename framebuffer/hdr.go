@@ -0,0 +1,102 @@
+package framebuffer
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// EncodePFM writes a color portable float map. PFM stores scanlines bottom to
+// top, a negative scale marks little endian data.
+func EncodePFM(w io.Writer, fb *Framebuffer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "PF\n%d %d\n-1.0\n", fb.Width, fb.Height)
+	row := make([]byte, 4*3*fb.Width)
+	for y := fb.Height - 1; y >= 0; y-- {
+		line := fb.Pix[fb.offset(0, y):fb.offset(0, y+1)]
+		for i, v := range line {
+			binary.LittleEndian.PutUint32(row[4*i:], math.Float32bits(v))
+		}
+		bw.Write(row)
+	}
+	return bw.Flush()
+}
+
+// EncodeHDR writes a Radiance picture with run length encoded RGBE scanlines.
+func EncodeHDR(w io.Writer, fb *Framebuffer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "#?RADIANCE\nFORMAT=32-bit_rle_rgbe\n\n-Y %d +X %d\n", fb.Height, fb.Width)
+
+	rgbe := make([]byte, 4*fb.Width)
+	component := make([]byte, fb.Width)
+	for y := 0; y < fb.Height; y++ {
+		for x := 0; x < fb.Width; x++ {
+			o := fb.offset(x, y)
+			toRGBE(rgbe[4*x:4*x+4], fb.Pix[o], fb.Pix[o+1], fb.Pix[o+2])
+		}
+		// Run length encoding is only defined for these widths, anything else is stored flat.
+		if fb.Width < 8 || fb.Width > 0x7fff {
+			bw.Write(rgbe)
+			continue
+		}
+		bw.Write([]byte{2, 2, byte(fb.Width >> 8), byte(fb.Width & 0xff)})
+		for c := 0; c < 4; c++ {
+			for x := range component {
+				component[x] = rgbe[4*x+c]
+			}
+			writeRLE(bw, component)
+		}
+	}
+	return bw.Flush()
+}
+
+func toRGBE(dst []byte, r, g, b float32) {
+	v := float64(max(r, g, b))
+	if v < 1e-32 {
+		dst[0], dst[1], dst[2], dst[3] = 0, 0, 0, 0
+		return
+	}
+	m, e := math.Frexp(v)
+	scale := m * 256 / v
+	dst[0] = byte(float64(max(r, 0)) * scale)
+	dst[1] = byte(float64(max(g, 0)) * scale)
+	dst[2] = byte(float64(max(b, 0)) * scale)
+	dst[3] = byte(e + 128)
+}
+
+// writeRLE encodes one component of a scanline: runs of at least minRun equal
+// bytes become (128+count, value), everything else is copied as (count, bytes...).
+func writeRLE(w *bufio.Writer, data []byte) {
+	const minRun = 4
+	cur := 0
+	for cur < len(data) {
+		begRun := cur
+		runCount, oldRunCount := 0, 0
+		// Find the next run that is long enough to be worth encoding.
+		for runCount < minRun && begRun < len(data) {
+			begRun += runCount
+			oldRunCount = runCount
+			runCount = 1
+			for begRun+runCount < len(data) && runCount < 127 && data[begRun] == data[begRun+runCount] {
+				runCount++
+			}
+		}
+		// A short run right before the long one is still cheaper as a run.
+		if oldRunCount > 1 && oldRunCount == begRun-cur {
+			w.Write([]byte{byte(128 + oldRunCount), data[cur]})
+			cur = begRun
+		}
+		for cur < begRun {
+			n := min(begRun-cur, 128)
+			w.WriteByte(byte(n))
+			w.Write(data[cur : cur+n])
+			cur += n
+		}
+		if runCount >= minRun {
+			w.Write([]byte{byte(128 + runCount), data[begRun]})
+			cur += runCount
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package framebuffer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestEncodePFM(t *testing.T) {
+	fb := testImage(5, 3)
+	buf := bytes.Buffer{}
+	if err := EncodePFM(&buf, fb); err != nil {
+		t.Fatal(err)
+	}
+	const header = "PF\n5 3\n-1.0\n"
+	if !bytes.HasPrefix(buf.Bytes(), []byte(header)) || buf.Len() != len(header)+4*3*5*3 {
+		t.Fatalf("got %d bytes starting with %q", buf.Len(), buf.Bytes()[:len(header)])
+	}
+	got, err := DecodePFM(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range fb.Pix {
+		if got.Pix[i] != v {
+			t.Fatalf("value %d is %v, want %v", i, got.Pix[i], v)
+		}
+	}
+}
+
+func TestEncodeHDR(t *testing.T) {
+	// Run length encoding needs scanlines of at least 8 pixels, the narrow
+	// image is stored flat.
+	for _, width := range []int{5, 40} {
+		fb := testImage(width, 3)
+		// A row of one color becomes runs.
+		for x := 0; x < width; x++ {
+			fb.SetColor(x, 1, fb.Color(0, 1))
+		}
+		buf := bytes.Buffer{}
+		if err := EncodeHDR(&buf, fb); err != nil {
+			t.Fatal(err)
+		}
+		data := buf.Bytes()
+		header := fmt.Sprintf("#?RADIANCE\nFORMAT=32-bit_rle_rgbe\n\n-Y 3 +X %d\n", width)
+		if !bytes.HasPrefix(data, []byte(header)) {
+			t.Fatalf("width %d: got header %q", width, data[:len(header)])
+		}
+		pixels := data[len(header):]
+		if width < 8 {
+			if len(pixels) != 4*width*3 {
+				t.Errorf("width %d: got %d bytes of flat scanlines", width, len(pixels))
+			}
+		} else {
+			if !bytes.HasPrefix(pixels, []byte{2, 2, 0, byte(width)}) {
+				t.Errorf("width %d: scanline starts with %v", width, pixels[:4])
+			}
+			if len(pixels) >= 4*width*3 {
+				t.Errorf("width %d: %d bytes, run length encoding saved nothing", width, len(pixels))
+			}
+			if !bytes.Contains(pixels, []byte{128 + 40}) {
+				t.Errorf("width %d: no run over the uniform row", width)
+			}
+		}
+
+		got, err := DecodeHDR(&buf)
+		if err != nil {
+			t.Fatalf("width %d: %v", width, err)
+		}
+		for i, v := range fb.Pix {
+			// RGBE keeps 8 bits of mantissa for the largest component.
+			o := i / 3 * 3
+			largest := max(fb.Pix[o], fb.Pix[o+1], fb.Pix[o+2])
+			if math.Abs(float64(got.Pix[i]-max(v, 0))) > float64(largest)/128 {
+				t.Errorf("width %d: value %d is %v, want %v", width, i, got.Pix[i], v)
+			}
+		}
+	}
+}
+
+func TestDecodeOversized(t *testing.T) {
+	for _, test := range []struct {
+		header string
+		decode func(io.Reader) (*Framebuffer, error)
+		fails  string
+	}{
+		{"PF\n2000000000 2000000000\n-1\n", DecodePFM, "too large"},
+		{"PF\n65536 65536\n-1\n", DecodePFM, "too large"},
+		{"Pf\n100000 1\n-1\n", DecodePFM, "too large"},
+		{"#?RADIANCE\n\n-Y 2000000000 +X 2000000000\n", DecodeHDR, "too large"},
+		{"#?RADIANCE\n\n-Y 1 +X 100000\n", DecodeHDR, "too large"},
+		// Sizes within the limits fail on the missing data.
+		{"PF\n8192 8192\n-1\n\x00\x00\x00\x00", DecodePFM, "data"},
+		{"#?RADIANCE\n\n-Y 8192 +X 8192\n\x00\x00\x00\x00", DecodeHDR, "scanline"},
+	} {
+		if _, err := test.decode(strings.NewReader(test.header)); err == nil || !strings.Contains(err.Error(), test.fails) {
+			t.Errorf("%q: got %v", test.header, err)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package framebuffer
+
+import (
+	"ray_tracing/util"
+	"ray_tracing/vector"
+)
+
+// heatmapRamp runs from few samples (dark blue) to many (red).
+var heatmapRamp = []vector.Color{
+	{0, 0, 0.3},
+	{0, 0.2, 1},
+	{0, 1, 1},
+	{1, 1, 0},
+	{1, 0, 0},
+}
+
+// SampleHeatmap renders the per-pixel sample counts of fb as colors, scaled
+// between the lowest and highest count. The colors are display values: they
+// are stored linearized so that the gamma of the 8 bit encoders (PNG, PPM)
+// brings back the ramp, whose position is linear in the count. The float
+// encoders write the linearized values.
+func SampleHeatmap(fb *Framebuffer) *Framebuffer {
+	heat := New(fb.Width, fb.Height)
+	copy(heat.Samples, fb.Samples)
+	if len(fb.Samples) == 0 {
+		return heat
+	}
+	lo, hi := fb.Samples[0], fb.Samples[0]
+	for _, n := range fb.Samples {
+		lo, hi = min(lo, n), max(hi, n)
+	}
+	for i, n := range fb.Samples {
+		t := 0.0
+		if hi > lo {
+			t = float64(n-lo) / float64(hi-lo)
+		}
+		c := rampColor(t)
+		heat.SetColor(i%fb.Width, i/fb.Width, vector.Color{util.GammaToLinear(c[0]), util.GammaToLinear(c[1]), util.GammaToLinear(c[2])})
+	}
+	return heat
+}
+
+func rampColor(t float64) vector.Color {
+	pos := t * float64(len(heatmapRamp)-1)
+	i := min(int(pos), len(heatmapRamp)-2)
+	f := pos - float64(i)
+	return heatmapRamp[i].Multiply(1 - f).Add(heatmapRamp[i+1].Multiply(f))
+}
@@ -0,0 +1,23 @@
+package framebuffer
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSampleHeatmap(t *testing.T) {
+	fb := New(3, 1)
+	copy(fb.Samples, []uint32{4, 12, 20})
+	heat := SampleHeatmap(fb)
+	// The 8 bit encoders show the ramp at 0, halfway and the end.
+	for x, t0 := range []float64{0, 0.5, 1} {
+		want := rampColor(t0)
+		r, g, b := heat.RGB8(x, 0)
+		for c, got := range []uint8{r, g, b} {
+			if math.Abs(float64(got)-255*want[c]) > 1 {
+				t.Errorf("pixel %d shows %d %d %d, want the ramp color %v", x, r, g, b, want)
+				break
+			}
+		}
+	}
+}
@@ -3,7 +3,6 @@ module ray_tracing
 go 1.21
 
 require (
-	github.com/seehuhn/mt19937 v1.0.0
 	golang.org/x/exp v0.0.0-20231127185646-65229373498e
 	gonum.org/v1/gonum v0.14.0
 )
@@ -0,0 +1,45 @@
+package hittable
+
+import (
+	"ray_tracing/ray"
+	"ray_tracing/sampler"
+	"ray_tracing/vector"
+)
+
+// BSDF is implemented by materials whose scattering can be sampled, evaluated
+// and asked for its density, which importance sampling techniques (light
+// sampling, multiple importance sampling) need. Materials that only implement
+// Scatter are treated as specular by the renderer.
+type BSDF interface {
+	// Sample picks a scattered direction, drawing from s. It returns false
+	// when the ray is absorbed.
+	Sample(rIn *ray.Ray, rec *HitRecord, s sampler.Sampler) (BSDFSample, bool)
+	// Eval returns the BSDF times the cosine between direction and the normal,
+	// 0 for specular lobes.
+	Eval(rIn *ray.Ray, rec *HitRecord, direction vector.Vector) vector.Color
+	// Pdf is the solid angle density with which Sample picks direction, 0 for specular lobes.
+	Pdf(rIn *ray.Ray, rec *HitRecord, direction vector.Vector) float64
+}
+
+type BSDFSample struct {
+	Direction vector.Vector
+	// Weight is Eval / Pdf for the sampled direction, for specular lobes the
+	// attenuation of the ray.
+	Weight vector.Color
+	Pdf    float64
+	// Specular lobes are delta distributions, Eval and Pdf cannot reproduce them.
+	Specular bool
+}
+
+// scatter implements Material.Scatter on top of BSDF.Sample.
+func scatter(b BSDF, rIn *ray.Ray, rec *HitRecord, s sampler.Sampler) (bool, *ray.Ray, vector.Color) {
+	bs, ok := b.Sample(rIn, rec, s)
+	if !ok {
+		return false, nil, vector.Color{0, 0, 0}
+	}
+	scattered := ray.Get()
+	scattered.Origin = rec.Point
+	scattered.Direction = bs.Direction
+	scattered.Time = rIn.Time
+	return true, scattered, bs.Weight
+}
@@ -0,0 +1,117 @@
+package hittable
+
+import (
+	"math"
+	"ray_tracing/concrand"
+	"ray_tracing/interval"
+	"ray_tracing/ray"
+	"ray_tracing/vector"
+	"testing"
+
+	"golang.org/x/exp/rand"
+)
+
+// randomSpheres is a scene like the final one of the book: many small spheres
+// around the origin.
+func randomSpheres(rng *rand.Rand, n int) []Hittable {
+	objects := make([]Hittable, n)
+	for i := range objects {
+		center := vector.Vector{rng.Float64()*22 - 11, 0.2, rng.Float64()*22 - 11}
+		objects[i] = NewSphere(center, 0.2, &Lambertian{})
+	}
+	return objects
+}
+
+func randomRays(rng *rand.Rand, n int) []ray.Ray {
+	rays := make([]ray.Ray, n)
+	for i := range rays {
+		origin := vector.Vector{rng.Float64()*4 - 2, 2 + rng.Float64(), rng.Float64()*4 - 2}
+		target := vector.Vector{rng.Float64()*22 - 11, 0, rng.Float64()*22 - 11}
+		rays[i] = ray.Ray{Origin: origin, Direction: target.Add(origin.Negative())}
+	}
+	return rays
+}
+
+func TestLinearBVHMatchesTree(t *testing.T) {
+	rng := concrand.New(7)
+	objects := randomSpheres(rng, 3000)
+	rays := randomRays(rng, 5000)
+	for name, tree := range map[string]*BVHNode{
+		"median": NewBHVTree(concrand.New(7), append([]Hittable(nil), objects...)...),
+		"SAH":    NewSAHTree(append([]Hittable(nil), objects...)...),
+		"single": NewBHVTree(concrand.New(7), objects[0]),
+	} {
+		linear := NewLinearBVH(tree)
+		if got, want := len(linear.prims), tree.Stats().Primitives; got != want {
+			t.Errorf("%s: flattened %d primitives, want %d", name, got, want)
+		}
+		for i := range rays {
+			var got, want HitRecord
+			hitGot := linear.Hit(&rays[i], interval.Interval{0.001, math.Inf(1)}, &got)
+			hitWant := tree.Hit(&rays[i], interval.Interval{0.001, math.Inf(1)}, &want)
+			if hitGot != hitWant || got.T != want.T || got.Point != want.Point {
+				t.Fatalf("%s, ray %v: linear hit %v at %v, tree hit %v at %v", name, rays[i], hitGot, got.T, hitWant, want.T)
+			}
+		}
+	}
+}
+
+// spheres is not comparable, comparing it as an interface value panics.
+type spheres []Sphere
+
+func (s spheres) Hit(r *ray.Ray, rayT interval.Interval, rec *HitRecord) bool {
+	hit := false
+	for i := range s {
+		if s[i].Hit(r, rayT, rec) {
+			hit, rayT = true, interval.Interval{rayT.Min(), rec.T}
+		}
+	}
+	return hit
+}
+
+func (s spheres) BoundingBox() interval.AABB {
+	return s[0].BoundingBox()
+}
+
+func TestSingleElementTree(t *testing.T) {
+	light := spheres{*NewSphere(vector.Point{0, 0, -1}, 0.5, NewDiffuseLight(vector.Color{1, 1, 1}))}
+	r := &ray.Ray{Origin: vector.Point{}, Direction: vector.Vector{0, 0, -1}}
+	for name, tree := range map[string]*BVHNode{
+		"median": NewBHVTree(concrand.New(1), light),
+		"SAH":    NewSAHTree(light),
+	} {
+		var rec HitRecord
+		if !tree.Hit(r, interval.Interval{0.001, math.Inf(1)}, &rec) || rec.T != 0.5 {
+			t.Errorf("%s: missed the object", name)
+		}
+		if s := tree.Stats(); s.Nodes != 1 || s.Primitives != 1 {
+			t.Errorf("%s: %d nodes and %d primitives", name, s.Nodes, s.Primitives)
+		}
+		if n := len(NewLinearBVH(tree).prims); n != 1 {
+			t.Errorf("%s: flattened %d primitives", name, n)
+		}
+		NewLights(tree)
+	}
+}
+
+func benchmarkHit(b *testing.B, world Hittable, rays []ray.Ray) {
+	rec := HitRecord{}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		world.Hit(&rays[i%len(rays)], interval.Interval{0.001, math.Inf(1)}, &rec)
+	}
+	b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "rays/s")
+}
+
+func BenchmarkHit(b *testing.B) {
+	rng := concrand.New(11)
+	objects := randomSpheres(rng, 20000)
+	rays := randomRays(rng, 1<<16)
+	median := NewBHVTree(concrand.New(11), append([]Hittable(nil), objects...)...)
+	sah := NewSAHTree(append([]Hittable(nil), objects...)...)
+
+	b.Run("BVHNode/median", func(b *testing.B) { benchmarkHit(b, median, rays) })
+	b.Run("BVHNode/SAH", func(b *testing.B) { benchmarkHit(b, sah, rays) })
+	b.Run("LinearBVH/median", func(b *testing.B) { benchmarkHit(b, NewLinearBVH(median), rays) })
+	b.Run("LinearBVH/SAH", func(b *testing.B) { benchmarkHit(b, NewLinearBVH(sah), rays) })
+}
@@ -4,15 +4,27 @@ import (
 	"math"
 	"ray_tracing/interval"
 	"ray_tracing/ray"
+	"ray_tracing/texture"
 	"ray_tracing/vector"
 	"sort"
+
+	"golang.org/x/exp/rand"
 )
 
 type HitRecord struct {
-	Point       vector.Point
-	Normal      vector.Vector
-	Material    Material
-	U, V        float64
+	Point    vector.Point
+	Normal   vector.Vector
+	Material Material
+	U, V     float64
+	// Tangent and Bitangent are unit vectors perpendicular to Normal, pointing
+	// where U and V grow. Together they give normal maps a frame.
+	Tangent, Bitangent vector.Vector
+	// DpDu and DpDv are how the point moves with U and V, unlike the tangents
+	// they keep their length.
+	DpDu, DpDv vector.Vector
+	// Footprint is the area of the surface a camera ray's pixel covers, set by
+	// ComputeFootprint.
+	Footprint   texture.Footprint
 	T           float64
 	IsFrontFace bool
 }
@@ -30,6 +42,42 @@ func (hr *HitRecord) SetFaceNormal(r *ray.Ray, outwardNormal vector.Vector) {
 
 }
 
+// ComputeFootprint intersects the differentials of r with the tangent plane
+// of the hit and expresses how far apart they land in U and V. Rays without
+// differentials, and surfaces whose UVs do not change, get a zero footprint.
+func (hr *HitRecord) ComputeFootprint(r *ray.Ray) {
+	hr.Footprint = texture.Footprint{}
+	if !r.HasDifferentials {
+		return
+	}
+	d := vector.Dot(hr.Normal, hr.Point)
+	tx := (d - vector.Dot(hr.Normal, r.RxOrigin)) / vector.Dot(hr.Normal, r.RxDirection)
+	ty := (d - vector.Dot(hr.Normal, r.RyOrigin)) / vector.Dot(hr.Normal, r.RyDirection)
+	if math.IsInf(tx, 0) || math.IsNaN(tx) || math.IsInf(ty, 0) || math.IsNaN(ty) {
+		return
+	}
+	dpdx := r.RxOrigin.Add(r.RxDirection.Multiply(tx)).Add(hr.Point.Negative())
+	dpdy := r.RyOrigin.Add(r.RyDirection.Multiply(ty)).Add(hr.Point.Negative())
+
+	// Least squares solution of dp = DpDu du + DpDv dv, the offsets need not
+	// lie exactly in the plane of the derivatives.
+	a00, a01, a11 := vector.Dot(hr.DpDu, hr.DpDu), vector.Dot(hr.DpDu, hr.DpDv), vector.Dot(hr.DpDv, hr.DpDv)
+	det := a00*a11 - a01*a01
+	if det == 0 || math.IsNaN(det) {
+		hr.Footprint.DpDx, hr.Footprint.DpDy = dpdx, dpdy
+		return
+	}
+	solve := func(dp vector.Vector) (float64, float64) {
+		bu, bv := vector.Dot(hr.DpDu, dp), vector.Dot(hr.DpDv, dp)
+		return (a11*bu - a01*bv) / det, (a00*bv - a01*bu) / det
+	}
+	du, dv := solve(dpdx)
+	hr.Footprint.DuDx, hr.Footprint.DvDx = du, dv
+	du, dv = solve(dpdy)
+	hr.Footprint.DuDy, hr.Footprint.DvDy = du, dv
+	hr.Footprint.DpDx, hr.Footprint.DpDy = dpdx, dpdy
+}
+
 type Hittable interface {
 	Hit(r *ray.Ray, rayT interval.Interval, rec *HitRecord) bool
 	BoundingBox() interval.AABB
@@ -105,12 +153,40 @@ func (s *Sphere) Hit(r *ray.Ray, rayT interval.Interval, rec *HitRecord) bool {
 	}
 	rec.T = root
 	rec.Point = r.At(rec.T)
-	outwardNormal := rec.Point.Add(s.Center.Negative()).Divide(s.Radius)
+	outwardNormal := rec.Point.Add(center.Negative()).Divide(s.Radius)
 	rec.SetFaceNormal(r, outwardNormal)
+	// Hollow spheres have a negative radius and an inward normal, their
+	// texture is not mirrored.
+	p := rec.Point.Add(center.Negative()).Divide(math.Abs(s.Radius))
+	rec.U, rec.V = sphereUV(p)
+	rec.Tangent, rec.Bitangent = sphereTangents(p, rec.Normal)
+	radius := math.Abs(s.Radius)
+	rec.DpDu = vector.Vector{p[2], 0, -p[0]}.Multiply(2 * math.Pi * radius)
+	rec.DpDv = rec.Bitangent.Multiply(math.Pi * radius)
 	rec.Material = s.Material
 	return true
 }
 
+// sphereUV maps a point p of the unit sphere to U, the angle around the Y
+// axis from X=-1, and V, the angle from Y=-1, both scaled to [0, 1].
+func sphereUV(p vector.Point) (float64, float64) {
+	theta := math.Acos(max(-1, min(1, -p[1])))
+	phi := math.Atan2(-p[2], p[0]) + math.Pi
+	return phi / (2 * math.Pi), theta / math.Pi
+}
+
+// sphereTangents returns the directions of growing U and V at p of the unit
+// sphere, normal is the side the hit is on.
+func sphereTangents(p vector.Point, normal vector.Vector) (vector.Vector, vector.Vector) {
+	tangent := vector.Vector{p[2], 0, -p[0]}
+	if tangent.LengthSquared() == 0 {
+		// U is undefined at the poles.
+		return vector.OrthonormalBasis(normal)
+	}
+	tangent = vector.UnitVector(tangent)
+	return tangent, vector.Cross(p, tangent)
+}
+
 type Plane struct {
 	Center   vector.Point
 	Normal   vector.Vector
@@ -151,14 +227,19 @@ func (hl *Hittables) Append(objects ...Hittable) {
 	}
 }
 
-func (hl *Hittables) ToBVHTree() *BVHNode {
-	return NewBHVTree(hl.objects...)
+func (hl *Hittables) ToBVHTree(rng *rand.Rand) *BVHNode {
+	return NewBHVTree(rng, hl.objects...)
+}
+
+// ToLinearBVH builds a SAH tree in parallel and flattens it.
+func (hl *Hittables) ToLinearBVH() *LinearBVH {
+	return NewLinearBVH(NewParallelSAHTree(hl.objects...))
 }
 
 func NewWorld(o ...Hittable) *Hittables {
-	return &Hittables{
-		objects: o,
-	}
+	hl := &Hittables{bbox: emptyAABB()}
+	hl.Append(o...)
+	return hl
 }
 
 func (hl *Hittables) Hit(r *ray.Ray, rayT interval.Interval, rec *HitRecord) bool {
@@ -178,17 +259,19 @@ func boxCompare(h1, h2 Hittable, axis int) bool {
 }
 
 type BVHNode struct {
+	// right is nil in single element leaves.
 	left, right Hittable
 	bbox        interval.AABB
 }
 
-func NewBHVTree(src ...Hittable) *BVHNode {
-	axis := randGen.Intn(2)
+// NewBHVTree builds the hierarchy over src, rng picks the split axes.
+func NewBHVTree(rng *rand.Rand, src ...Hittable) *BVHNode {
+	axis := rng.Intn(2)
 
 	node := BVHNode{}
 	switch len(src) {
 	case 1:
-		node.left, node.right = src[0], src[0]
+		node.left = src[0]
 	case 2:
 		if boxCompare(src[0], src[1], axis) {
 			node.left = src[0]
@@ -200,10 +283,13 @@ func NewBHVTree(src ...Hittable) *BVHNode {
 	default:
 		sort.Slice(src, func(i, j int) bool { return boxCompare(src[i], src[j], axis) })
 		middle := len(src) / 2
-		node.left = NewBHVTree(src[:middle]...)
-		node.right = NewBHVTree(src[middle:]...)
+		node.left = NewBHVTree(rng, src[:middle]...)
+		node.right = NewBHVTree(rng, src[middle:]...)
+	}
+	node.bbox = node.left.BoundingBox()
+	if node.right != nil {
+		node.bbox = interval.CombineAABB(node.bbox, node.right.BoundingBox())
 	}
-	node.bbox = interval.CombineAABB(node.left.BoundingBox(), node.right.BoundingBox())
 	return &node
 }
 
@@ -218,6 +304,9 @@ func (b *BVHNode) Hit(r *ray.Ray, rayT interval.Interval, rec *HitRecord) bool {
 
 	var maxT float64
 	hitLeft := b.left.Hit(r, rayT, rec)
+	if b.right == nil {
+		return hitLeft
+	}
 	if hitLeft {
 		maxT = rec.T
 	} else {
@@ -0,0 +1,119 @@
+package hittable
+
+import (
+	"math"
+	"ray_tracing/interval"
+	"ray_tracing/ray"
+	"ray_tracing/texture"
+	"ray_tracing/vector"
+	"testing"
+)
+
+func TestSphereUV(t *testing.T) {
+	for _, test := range []struct {
+		p    vector.Point
+		u, v float64
+	}{
+		{vector.Point{1, 0, 0}, 0.5, 0.5},
+		{vector.Point{-1, 0, 0}, 0, 0.5},
+		{vector.Point{0, 1, 0}, 0.5, 1},
+		{vector.Point{0, -1, 0}, 0.5, 0},
+		{vector.Point{0, 0, 1}, 0.25, 0.5},
+		{vector.Point{0, 0, -1}, 0.75, 0.5},
+	} {
+		if u, v := sphereUV(test.p); math.Abs(u-test.u) > 1e-12 || math.Abs(v-test.v) > 1e-12 {
+			t.Errorf("sphereUV(%v) = %v, %v, want %v, %v", test.p, u, v, test.u, test.v)
+		}
+	}
+}
+
+// TestSphereHitMoving checks a moving sphere at the end of its move: the
+// normal, UVs and tangents are those of a sphere resting there.
+func TestSphereHitMoving(t *testing.T) {
+	moving := NewSphere(vector.Point{0, 0, 0}, 1, nil)
+	moving.MoveTo(vector.Point{0, 2, 0})
+	still := NewSphere(vector.Point{0, 2, 0}, 1, nil)
+
+	r := &ray.Ray{Origin: vector.Point{3, 2.5, 0.5}, Direction: vector.Vector{-1, 0, 0}, Time: 1}
+	var got, want HitRecord
+	if !moving.Hit(r, interval.Interval{0.001, math.Inf(1)}, &got) || !still.Hit(r, interval.Interval{0.001, math.Inf(1)}, &want) {
+		t.Fatal("ray missed")
+	}
+	if got != want {
+		t.Errorf("moving sphere hit %+v, resting one %+v", got, want)
+	}
+	if d := vector.Dot(got.Normal, got.Tangent); math.Abs(d) > 1e-12 {
+		t.Errorf("tangent is %v off the surface", d)
+	}
+
+	// Moving along the tangent grows U.
+	step := HitRecord{}
+	r2 := &ray.Ray{Origin: r.Origin.Add(got.Tangent.Multiply(1e-4)), Direction: r.Direction, Time: 1}
+	still.Hit(r2, interval.Interval{0.001, math.Inf(1)}, &step)
+	if step.U <= got.U {
+		t.Errorf("U went from %v to %v along the tangent", got.U, step.U)
+	}
+}
+
+func TestTriangleTangents(t *testing.T) {
+	tri := NewTriangle(vector.Point{0, 0, 0}, vector.Point{2, 0, 0}, vector.Point{0, 0, -2}, nil)
+	// U runs along -Z and V along +X.
+	tri.SetUVs([2]float64{0, 0}, [2]float64{0, 1}, [2]float64{1, 0})
+	rec := HitRecord{}
+	if !tri.Hit(&ray.Ray{Origin: vector.Point{0.5, 1, -0.5}, Direction: vector.Vector{0, -1, 0}}, interval.Interval{0.001, math.Inf(1)}, &rec) {
+		t.Fatal("ray missed")
+	}
+	if rec.Tangent != (vector.Vector{0, 0, -1}) || rec.Bitangent != (vector.Vector{1, 0, 0}) {
+		t.Errorf("got tangent %v, bitangent %v", rec.Tangent, rec.Bitangent)
+	}
+}
+
+func TestComputeFootprint(t *testing.T) {
+	// A floor at Y=0 whose texture repeats every two units along X and Z.
+	tri := NewTriangle(vector.Point{0, 0, 0}, vector.Point{2, 0, 0}, vector.Point{0, 0, -2}, nil)
+	tri.SetUVs([2]float64{0, 0}, [2]float64{1, 0}, [2]float64{0, 1})
+
+	// Looking straight down, the differentials are 0.1 apart on the floor.
+	r := &ray.Ray{
+		Origin:           vector.Point{0.5, 1, -0.5},
+		Direction:        vector.Vector{0, -1, 0},
+		HasDifferentials: true,
+		RxOrigin:         vector.Point{0.5, 1, -0.5},
+		RxDirection:      vector.Vector{0.1, -1, 0},
+		RyOrigin:         vector.Point{0.5, 1, -0.5},
+		RyDirection:      vector.Vector{0, -1, 0.1},
+	}
+	rec := HitRecord{}
+	if !tri.Hit(r, interval.Interval{0.001, math.Inf(1)}, &rec) {
+		t.Fatal("ray missed")
+	}
+	rec.ComputeFootprint(r)
+	want := [4]float64{0.05, 0, 0, -0.05}
+	got := [4]float64{rec.Footprint.DuDx, rec.Footprint.DvDx, rec.Footprint.DuDy, rec.Footprint.DvDy}
+	for i := range got {
+		if math.Abs(got[i]-want[i]) > 1e-12 {
+			t.Fatalf("got derivatives %v, want %v", got, want)
+		}
+	}
+	if d := rec.Footprint.DpDx.Add(vector.Vector{-0.1, 0, 0}); !d.IsCloseToZero() {
+		t.Errorf("got DpDx %v", rec.Footprint.DpDx)
+	}
+
+	// Twice as far through a scaled instance, the footprint doubles in world
+	// space but the texture scales along.
+	in := NewInstance(tri, vector.Scale(2, 2, 2))
+	r.Origin, r.RxOrigin, r.RyOrigin = vector.Point{1, 2, -1}, vector.Point{1, 2, -1}, vector.Point{1, 2, -1}
+	if !in.Hit(r, interval.Interval{0.001, math.Inf(1)}, &rec) {
+		t.Fatal("ray missed the instance")
+	}
+	rec.ComputeFootprint(r)
+	if d := rec.Footprint.DpDx.Add(vector.Vector{-0.2, 0, 0}); math.Abs(rec.Footprint.DuDx-0.05) > 1e-12 || !d.IsCloseToZero() {
+		t.Errorf("got footprint %+v through the instance", rec.Footprint)
+	}
+
+	r.HasDifferentials = false
+	rec.ComputeFootprint(r)
+	if rec.Footprint != (texture.Footprint{}) {
+		t.Errorf("a ray without differentials got footprint %+v", rec.Footprint)
+	}
+}
@@ -0,0 +1,104 @@
+package hittable
+
+import (
+	"math"
+	"ray_tracing/interval"
+	"ray_tracing/ray"
+	"ray_tracing/vector"
+)
+
+// Instance places Object in the world with Transform. Objects are shared, the
+// same mesh can be instanced any number of times. Make it with NewInstance,
+// which caches the bounding box and the inverse of Transform.
+type Instance struct {
+	Object    Hittable
+	Transform vector.Transform
+	// inverse is Transform.Inverse(), built once instead of on every ray.
+	inverse vector.Transform
+	bbox    interval.AABB
+}
+
+func NewInstance(object Hittable, transform vector.Transform) *Instance {
+	return &Instance{
+		Object:    object,
+		Transform: transform,
+		inverse:   transform.Inverse(),
+		bbox:      transformBox(object.BoundingBox(), transform),
+	}
+}
+
+func (in *Instance) BoundingBox() interval.AABB {
+	return in.bbox
+}
+
+// Hit traces the ray in object space. The direction is not normalized there,
+// so the ray parameter of a hit is the same in both spaces.
+func (in *Instance) Hit(r *ray.Ray, rayT interval.Interval, rec *HitRecord) bool {
+	objectRay := ray.Ray{
+		Origin:    in.inverse.Point(r.Origin),
+		Direction: in.inverse.Vector(r.Direction),
+		Time:      r.Time,
+	}
+	if !in.Object.Hit(&objectRay, rayT, rec) {
+		return false
+	}
+	// Affine transforms keep the sign of the normal against the ray, so
+	// IsFrontFace stays valid.
+	rec.Point = in.Transform.Point(rec.Point)
+	rec.Normal = vector.UnitVector(in.Transform.Normal(rec.Normal))
+	// Tangents stay on the surface, but shears and non uniform scales take
+	// away their right angle.
+	tangent := in.Transform.Vector(rec.Tangent)
+	rec.Tangent = vector.UnitVector(tangent.Add(rec.Normal.Multiply(-vector.Dot(rec.Normal, tangent))))
+	bitangent := in.Transform.Vector(rec.Bitangent)
+	bitangent = bitangent.Add(rec.Normal.Multiply(-vector.Dot(rec.Normal, bitangent))).
+		Add(rec.Tangent.Multiply(-vector.Dot(rec.Tangent, bitangent)))
+	rec.Bitangent = vector.UnitVector(bitangent)
+	rec.DpDu, rec.DpDv = in.Transform.Vector(rec.DpDu), in.Transform.Vector(rec.DpDv)
+	return true
+}
+
+// transformBox bounds the transformed corners of box.
+func transformBox(box interval.AABB, t vector.Transform) interval.AABB {
+	out := interval.AABB{interval.Empty, interval.Empty, interval.Empty}
+	for c := 0; c < 8; c++ {
+		corner := vector.Point{box[0][c&1], box[1][(c>>1)&1], box[2][(c>>2)&1]}
+		p := t.Point(corner)
+		out = interval.CombineAABB(out, interval.NewAABB(interval.FromPoints(p, p)))
+	}
+	return out
+}
+
+// instanceLight samples a light inside an Instance in object space.
+type instanceLight struct {
+	light     LightSampler
+	transform vector.Transform
+	inverse   vector.Transform
+	det       float64
+}
+
+func (l *instanceLight) SampleDirection(origin vector.Point, time, u, v float64) vector.Vector {
+	d := l.light.SampleDirection(l.inverse.Point(origin), time, u, v)
+	return l.transform.Vector(d)
+}
+
+// PdfValue converts the object space density with the Jacobian of the
+// transform's linear part A on directions: dω' = |det A| / |A·ω|³ dω.
+func (l *instanceLight) PdfValue(origin vector.Point, direction vector.Vector, time float64) float64 {
+	d := l.inverse.Vector(direction)
+	pdf := l.light.PdfValue(l.inverse.Point(origin), d, time)
+	if pdf == 0 {
+		return 0
+	}
+	stretch := l.transform.Vector(vector.UnitVector(d)).Length()
+	return pdf * stretch * stretch * stretch / l.det
+}
+
+func newInstanceLight(light LightSampler, transform vector.Transform) *instanceLight {
+	return &instanceLight{
+		light:     light,
+		transform: transform,
+		inverse:   transform.Inverse(),
+		det:       math.Abs(transform.M.Determinant3()),
+	}
+}
@@ -0,0 +1,60 @@
+package hittable
+
+import (
+	"math"
+	"ray_tracing/concrand"
+	"ray_tracing/interval"
+	"ray_tracing/ray"
+	"ray_tracing/vector"
+	"testing"
+)
+
+func TestInstanceMatchesMovedSphere(t *testing.T) {
+	unit := NewSphere(vector.Point{0, 0, 0}, 1, &Lambertian{})
+	instance := NewInstance(unit, vector.Scale(2, 2, 2).Then(vector.RotateY(30)).Then(vector.Translate(vector.Vector{3, 0, -1})))
+	moved := NewSphere(vector.Point{3, 0, -1}, 2, &Lambertian{})
+	// The box of the rotated box of the sphere is larger than the sphere's.
+	if got, want := instance.BoundingBox(), moved.BoundingBox(); got != interval.CombineAABB(got, want) {
+		t.Errorf("box %v does not hold %v", got, want)
+	}
+
+	rng := concrand.New(3)
+	for i := 0; i < 1000; i++ {
+		r := &ray.Ray{Origin: vector.RandomBounded(rng, -6, 6), Direction: vector.RandomUnitVector(rng)}
+		var got, want HitRecord
+		hitGot := instance.Hit(r, interval.Interval{0.001, math.Inf(1)}, &got)
+		hitWant := moved.Hit(r, interval.Interval{0.001, math.Inf(1)}, &want)
+		if hitGot != hitWant {
+			t.Fatalf("ray %v: instance hit %v, sphere hit %v", r, hitGot, hitWant)
+		}
+		if !hitGot {
+			continue
+		}
+		dp, dn := got.Point.Add(want.Point.Negative()), got.Normal.Add(want.Normal.Negative())
+		if math.Abs(got.T-want.T) > 1e-9 || dp.Length() > 1e-9 || dn.Length() > 1e-9 || got.IsFrontFace != want.IsFrontFace {
+			t.Fatalf("ray %v: instance hit %+v, sphere hit %+v", r, got, want)
+		}
+	}
+}
+
+// TestInstanceLightPdfNormalized samples a light through a non uniform scale.
+func TestInstanceLightPdfNormalized(t *testing.T) {
+	light := NewSphere(vector.Point{0, 0, 0}, 1, NewDiffuseLight(vector.Color{1, 1, 1}))
+	world := NewWorld(NewInstance(light, vector.Scale(3, 1, 0.5).Then(vector.Translate(vector.Vector{0, 4, 0}))))
+	lights := NewLights(world)
+	if lights.Len() != 1 {
+		t.Fatalf("found %d lights", lights.Len())
+	}
+	origin := vector.Point{0.5, 0, 0}
+	const n = 1000
+	integral := 0.0
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			d := vector.SampleUnitVector((float64(i)+0.5)/n, (float64(j)+0.5)/n)
+			integral += lights.Pdf(origin, d, 0) * 4 * math.Pi / (n * n)
+		}
+	}
+	if math.Abs(integral-1) > 0.02 {
+		t.Errorf("Pdf integrates to %v", integral)
+	}
+}
@@ -0,0 +1,201 @@
+package hittable
+
+import (
+	"math"
+	"ray_tracing/interval"
+	"ray_tracing/ray"
+	"ray_tracing/sampler"
+	"ray_tracing/vector"
+	"sort"
+)
+
+// LightSampler is implemented by shapes that can be sampled by solid angle as
+// seen from a point, which is what explicit light sampling needs.
+type LightSampler interface {
+	// SampleDirection returns a direction from origin towards the shape, warped from u and v.
+	SampleDirection(origin vector.Point, time, u, v float64) vector.Vector
+	// PdfValue is the solid angle density of SampleDirection, 0 for directions missing the shape.
+	PdfValue(origin vector.Point, direction vector.Vector, time float64) float64
+}
+
+// Unwrapper is implemented by Hittables defined elsewhere that wrap the ones
+// of this package, NewLights looks inside them.
+type Unwrapper interface {
+	Unwrap() Hittable
+}
+
+// Lights are the emissive shapes of a world that can be sampled directly.
+type Lights struct {
+	lights []LightSampler
+}
+
+// NewLights collects every shape of world that has an Emitter material and implements LightSampler.
+func NewLights(world Hittable) *Lights {
+	l := &Lights{}
+	l.collect(world)
+	return l
+}
+
+func (l *Lights) collect(h Hittable) {
+	switch o := h.(type) {
+	case *Hittables:
+		for _, child := range o.objects {
+			l.collect(child)
+		}
+	case *BVHNode:
+		l.collect(o.left)
+		if o.right != nil {
+			l.collect(o.right)
+		}
+	case *LinearBVH:
+		for _, p := range o.prims {
+			l.collect(p)
+		}
+	case *Sphere:
+		if _, ok := o.Material.(Emitter); ok {
+			l.lights = append(l.lights, o)
+		}
+	case *Triangle:
+		if _, ok := o.Material.(Emitter); ok {
+			l.lights = append(l.lights, o)
+		}
+	case *TriangleMesh:
+		if light := newMeshLight(o); light != nil {
+			l.lights = append(l.lights, light)
+		}
+	case *Instance:
+		inner := NewLights(o.Object)
+		for _, light := range inner.lights {
+			l.lights = append(l.lights, newInstanceLight(light, o.Transform))
+		}
+	case Unwrapper:
+		l.collect(o.Unwrap())
+	}
+}
+
+func (l *Lights) Len() int {
+	return len(l.lights)
+}
+
+// Sample picks a light uniformly and a direction towards it, drawing from s.
+func (l *Lights) Sample(origin vector.Point, time float64, s sampler.Sampler) vector.Vector {
+	i := min(int(s.Get1D()*float64(len(l.lights))), len(l.lights)-1)
+	u, v := s.Get2D()
+	return l.lights[i].SampleDirection(origin, time, u, v)
+}
+
+// Pdf is the density of Sample for direction, averaged over all the lights.
+func (l *Lights) Pdf(origin vector.Point, direction vector.Vector, time float64) float64 {
+	if len(l.lights) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, light := range l.lights {
+		sum += light.PdfValue(origin, direction, time)
+	}
+	return sum / float64(len(l.lights))
+}
+
+// SampleDirection samples the cone the sphere subtends from origin uniformly.
+func (s *Sphere) SampleDirection(origin vector.Point, time, u, v float64) vector.Vector {
+	toCenter := s.CenterAt(time).Add(origin.Negative())
+	distanceSquared := toCenter.LengthSquared()
+	if distanceSquared <= s.Radius*s.Radius {
+		return vector.SampleUnitVector(u, v)
+	}
+	cosThetaMax := math.Sqrt(1 - s.Radius*s.Radius/distanceSquared)
+	z := 1 + v*(cosThetaMax-1)
+	phi := 2 * math.Pi * u
+	sinTheta := math.Sqrt(math.Max(0, 1-z*z))
+	tangent, bitangent := vector.OrthonormalBasis(vector.UnitVector(toCenter))
+	return tangent.Multiply(math.Cos(phi) * sinTheta).
+		Add(bitangent.Multiply(math.Sin(phi) * sinTheta)).
+		Add(vector.UnitVector(toCenter).Multiply(z))
+}
+
+func (s *Sphere) PdfValue(origin vector.Point, direction vector.Vector, time float64) float64 {
+	r := ray.Ray{Origin: origin, Direction: direction, Time: time}
+	rec := HitRecord{}
+	if !s.Hit(&r, interval.Interval{0.001, math.Inf(1)}, &rec) {
+		return 0
+	}
+	toCenter := s.CenterAt(time).Add(origin.Negative())
+	distanceSquared := toCenter.LengthSquared()
+	if distanceSquared <= s.Radius*s.Radius {
+		return 1 / (4 * math.Pi)
+	}
+	cosThetaMax := math.Sqrt(1 - s.Radius*s.Radius/distanceSquared)
+	return 1 / (2 * math.Pi * (1 - cosThetaMax))
+}
+
+// meshLight samples the emissive faces of a mesh as one light. Faces are
+// picked in proportion to their area, so the density is uniform over the
+// emitting surface and PdfValue does not depend on which face is hit.
+type meshLight struct {
+	mesh  *TriangleMesh
+	faces []uint32  // emissive faces
+	cdf   []float64 // area of the faces up to and including each one
+}
+
+// newMeshLight returns nil for meshes without emissive faces.
+func newMeshLight(m *TriangleMesh) *meshLight {
+	l := &meshLight{mesh: m}
+	total := 0.0
+	for i := 0; i < m.Len(); i++ {
+		if _, ok := m.material(uint32(i)).(Emitter); ok && m.area(i) > 0 {
+			total += m.area(i)
+			l.faces = append(l.faces, uint32(i))
+			l.cdf = append(l.cdf, total)
+		}
+	}
+	if len(l.faces) == 0 {
+		return nil
+	}
+	return l
+}
+
+func (l *meshLight) area() float64 {
+	return l.cdf[len(l.cdf)-1]
+}
+
+func (l *meshLight) SampleDirection(origin vector.Point, time, u, v float64) vector.Vector {
+	// u picks the face, what is left of it places the point.
+	target := u * l.area()
+	k := min(sort.SearchFloat64s(l.cdf, target), len(l.faces)-1)
+	lo := 0.0
+	if k > 0 {
+		lo = l.cdf[k-1]
+	}
+	u = min(max((target-lo)/(l.cdf[k]-lo), 0), 1)
+
+	a, b, c := l.mesh.vertices(l.faces[k])
+	su := math.Sqrt(u)
+	b0, b1 := 1-su, v*su
+	p := a.Multiply(b0).Add(b.Multiply(b1)).Add(c.Multiply(1 - b0 - b1))
+	return p.Add(origin.Negative())
+}
+
+// PdfValue adds up the emissive faces along direction, a ray can cross
+// several of them.
+func (l *meshLight) PdfValue(origin vector.Point, direction vector.Vector, time float64) float64 {
+	r := ray.Ray{Origin: origin, Direction: direction, Time: time}
+	unit := vector.UnitVector(direction)
+	pdf := 0.0
+	rayT := interval.Interval{0.001, math.Inf(1)}
+	for {
+		t, i, _, ok := l.mesh.closestHit(&r, rayT)
+		if !ok {
+			return pdf
+		}
+		rayT[0] = t
+		if _, ok := l.mesh.material(i).(Emitter); !ok {
+			continue
+		}
+		a, b, c := l.mesh.vertices(i)
+		normal := vector.Cross(b.Add(a.Negative()), c.Add(a.Negative()))
+		cosine := math.Abs(vector.Dot(unit, normal)) / normal.Length()
+		if cosine > 0 {
+			pdf += t * t * direction.LengthSquared() / (cosine * l.area())
+		}
+	}
+}
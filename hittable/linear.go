@@ -0,0 +1,120 @@
+package hittable
+
+import (
+	"ray_tracing/interval"
+	"ray_tracing/ray"
+)
+
+// linearNode is a node of a hierarchy flattened into an array in depth first
+// order. Inner nodes have their left child right after them and the right one
+// at offset, leaves (count > 0) cover count primitives starting at offset.
+type linearNode struct {
+	bbox   interval.AABB
+	offset uint32
+	count  uint32
+	axis   uint8
+}
+
+// LinearBVH is a BVHNode tree flattened into one array, traversed without
+// recursion or interface calls until the primitives.
+type LinearBVH struct {
+	nodes []linearNode
+	prims []Hittable
+}
+
+// NewLinearBVH flattens the tree of root. Leaves made of a Hittables are
+// unpacked, their objects become the primitives of one node.
+func NewLinearBVH(root *BVHNode) *LinearBVH {
+	l := &LinearBVH{}
+	l.flatten(root)
+	return l
+}
+
+func (l *LinearBVH) flatten(h Hittable) {
+	index := len(l.nodes)
+	l.nodes = append(l.nodes, linearNode{bbox: h.BoundingBox()})
+
+	var leaf []Hittable
+	switch o := h.(type) {
+	case *BVHNode:
+		if o.right == nil {
+			leaf = []Hittable{o.left}
+			break
+		}
+		l.nodes[index].axis = uint8(longestAxis(o.bbox))
+		l.flatten(o.left)
+		l.nodes[index].offset = uint32(len(l.nodes))
+		l.flatten(o.right)
+		return
+	case *Hittables:
+		leaf = o.objects
+	default:
+		leaf = []Hittable{o}
+	}
+	if len(leaf) == 0 {
+		// Would read as an inner node, but no ray gets through an empty box.
+		l.nodes[index].bbox = emptyAABB()
+	}
+	l.nodes[index].offset = uint32(len(l.prims))
+	l.nodes[index].count = uint32(len(leaf))
+	l.prims = append(l.prims, leaf...)
+}
+
+// longestAxis is the axis children are ordered along. The builders split
+// along different axes, the longest one is a good guess for all of them.
+func longestAxis(box interval.AABB) int {
+	axis := 0
+	for a := 1; a < 3; a++ {
+		if box[a].Size() > box[axis].Size() {
+			axis = a
+		}
+	}
+	return axis
+}
+
+func (l *LinearBVH) BoundingBox() interval.AABB {
+	if len(l.nodes) == 0 {
+		return emptyAABB()
+	}
+	return l.nodes[0].bbox
+}
+
+func (l *LinearBVH) Hit(r *ray.Ray, rayT interval.Interval, rec *HitRecord) bool {
+	if len(l.nodes) == 0 {
+		return false
+	}
+	closest := rayT
+	hit := false
+
+	stack := make([]uint32, 0, 64)
+	node := uint32(0)
+	for {
+		n := &l.nodes[node]
+		if n.bbox.Hit(r, closest) {
+			if n.count > 0 {
+				for _, p := range l.prims[n.offset : n.offset+n.count] {
+					if p.Hit(r, closest, rec) {
+						closest[1] = rec.T
+						hit = true
+					}
+				}
+			} else {
+				// Visit the child on the side the ray comes from first, once it
+				// is hit the far one only passes its box test if it is closer.
+				near, far := node+1, n.offset
+				if r.Direction[n.axis] < 0 {
+					near, far = far, near
+				}
+				stack = append(stack, far)
+				node = near
+				continue
+			}
+		}
+		if len(stack) == 0 {
+			break
+		}
+		node = stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+	}
+	return hit
+}
@@ -3,15 +3,41 @@ package hittable
 import (
 	"math"
 	"ray_tracing/ray"
+	"ray_tracing/sampler"
+	"ray_tracing/texture"
 	"ray_tracing/vector"
 )
 
+// Material is the minimal scattering interface, the materials of this package
+// also implement BSDF and derive Scatter from it.
 type Material interface {
-	Scatter(rIn *ray.Ray, rec *HitRecord) (bool, *ray.Ray, vector.Color)
+	// Scatter draws every random number it needs from s, positioned on the current sample.
+	Scatter(rIn *ray.Ray, rec *HitRecord, s sampler.Sampler) (bool, *ray.Ray, vector.Color)
 }
 
+// Emitter is implemented by materials that give off light, Emitted is added to
+// whatever the material scatters.
+type Emitter interface {
+	Emitted(u, v float64, p vector.Point) vector.Color
+}
+
+// Lambertian scatters diffusely. Its textures, like those of the other
+// materials, are sampled at the hit's U, V and point.
 type Lambertian struct {
-	Albedo vector.Color
+	Albedo texture.Texture
+}
+
+func NewLambertian(albedo vector.Color) *Lambertian {
+	return &Lambertian{Albedo: texture.NewSolidColor(albedo)}
+}
+
+// value samples t over the footprint of the hit, or returns fallback for
+// optional textures that are not set.
+func value(t texture.Texture, rec *HitRecord, fallback vector.Color) vector.Color {
+	if t == nil {
+		return fallback
+	}
+	return texture.Filtered(t, rec.U, rec.V, rec.Point, &rec.Footprint)
 }
 
 //	func (l *Lambertian) Scatter(rIn, rScattered *ray.Ray, rec *HitRecord, attenuation *vector.Color) bool {
@@ -24,24 +50,49 @@ type Lambertian struct {
 //		return true
 //	}
 
-func (l *Lambertian) Scatter(rIn *ray.Ray, rec *HitRecord) (bool, *ray.Ray, vector.Color) {
-	scatterDirection := rec.Normal.Add(vector.RandomUnitVector())
+func (l *Lambertian) Scatter(rIn *ray.Ray, rec *HitRecord, s sampler.Sampler) (bool, *ray.Ray, vector.Color) {
+	return scatter(l, rIn, rec, s)
+}
+
+// Sample draws from the cosine distribution around the normal, by offsetting
+// it with a uniform unit vector.
+func (l *Lambertian) Sample(rIn *ray.Ray, rec *HitRecord, s sampler.Sampler) (BSDFSample, bool) {
+	scatterDirection := rec.Normal.Add(vector.SampleUnitVector(s.Get2D()))
 	if scatterDirection.IsCloseToZero() {
 		scatterDirection = rec.Normal
 	}
+	return BSDFSample{
+		Direction: scatterDirection,
+		Weight:    value(l.Albedo, rec, vector.Color{}),
+		Pdf:       l.Pdf(rIn, rec, scatterDirection),
+	}, true
+}
 
-	r := ray.Get()
-	r.Origin = rec.Point
-	r.Direction = scatterDirection
-	r.Time = rIn.Time
-
-	return true, r, l.Albedo
+func (l *Lambertian) Eval(rIn *ray.Ray, rec *HitRecord, direction vector.Vector) vector.Color {
+	return value(l.Albedo, rec, vector.Color{}).Multiply(l.Pdf(rIn, rec, direction))
+}
 
+func (l *Lambertian) Pdf(rIn *ray.Ray, rec *HitRecord, direction vector.Vector) float64 {
+	cosine := vector.Dot(rec.Normal, vector.UnitVector(direction))
+	return math.Max(0, cosine/math.Pi)
 }
 
 type Metal struct {
-	Albedo    vector.Color
+	Albedo    texture.Texture
 	Fuzziness float64 //0 <= x < 1
+	// Roughness, if set, replaces Fuzziness with the first channel of its value.
+	Roughness texture.Texture
+}
+
+func NewMetal(albedo vector.Color, fuzziness float64) *Metal {
+	return &Metal{Albedo: texture.NewSolidColor(albedo), Fuzziness: fuzziness}
+}
+
+func (l *Metal) fuzziness(rec *HitRecord) float64 {
+	if l.Roughness == nil {
+		return l.Fuzziness
+	}
+	return value(l.Roughness, rec, vector.Color{})[0]
 }
 
 // func (l *Metal) Scatter(rIn, rScattered *ray.Ray, rec *HitRecord, attenuation *vector.Color) bool {
@@ -52,25 +103,81 @@ type Metal struct {
 
 // }
 
-func (l *Metal) Scatter(rIn *ray.Ray, rec *HitRecord) (bool, *ray.Ray, vector.Color) {
-	reflected := vector.Reflect(vector.UnitVector(rIn.Direction), rec.Normal)
+func (l *Metal) Scatter(rIn *ray.Ray, rec *HitRecord, s sampler.Sampler) (bool, *ray.Ray, vector.Color) {
+	return scatter(l, rIn, rec, s)
+}
 
-	scattered := ray.Get()
-	scattered.Origin = rec.Point
-	scattered.Direction = reflected.Add(vector.RandomUnitVector().Multiply(l.Fuzziness))
-	scattered.Time = rIn.Time
+// Sample offsets the mirror direction by a point of the sphere of radius
+// Fuzziness around its tip. Directions below the surface are absorbed.
+func (l *Metal) Sample(rIn *ray.Ray, rec *HitRecord, s sampler.Sampler) (BSDFSample, bool) {
+	fuzz := l.fuzziness(rec)
+	reflected := vector.Reflect(vector.UnitVector(rIn.Direction), rec.Normal)
+	direction := reflected.Add(vector.SampleUnitVector(s.Get2D()).Multiply(fuzz))
+	if vector.Dot(direction, rec.Normal) <= 0.0 {
+		return BSDFSample{}, false
+	}
+	albedo := value(l.Albedo, rec, vector.Color{})
+	if fuzz <= 0 {
+		return BSDFSample{Direction: direction, Weight: albedo, Specular: true}, true
+	}
+	return BSDFSample{
+		Direction: direction,
+		Weight:    albedo,
+		Pdf:       l.Pdf(rIn, rec, direction),
+	}, true
+}
 
-	return vector.Dot(scattered.Direction, rec.Normal) > 0.0, scattered, l.Albedo
+func (l *Metal) Eval(rIn *ray.Ray, rec *HitRecord, direction vector.Vector) vector.Color {
+	if vector.Dot(direction, rec.Normal) <= 0.0 {
+		return vector.Color{0, 0, 0}
+	}
+	return value(l.Albedo, rec, vector.Color{}).Multiply(l.Pdf(rIn, rec, direction))
+}
 
+// Pdf projects the fuzz sphere onto the directions: each point where direction
+// crosses the sphere contributes its uniform area density, times the squared
+// distance over the cosine to the sphere's surface.
+func (l *Metal) Pdf(rIn *ray.Ray, rec *HitRecord, direction vector.Vector) float64 {
+	fuzz := l.fuzziness(rec)
+	if fuzz <= 0 {
+		return 0
+	}
+	reflected := vector.Reflect(vector.UnitVector(rIn.Direction), rec.Normal)
+	d := vector.UnitVector(direction)
+	b := vector.Dot(d, reflected)
+	discriminant := b*b - 1 + fuzz*fuzz
+	if discriminant < 0 {
+		return 0
+	}
+	sqrtd := math.Sqrt(discriminant)
+	pdf := 0.0
+	for _, t := range [2]float64{b - sqrtd, b + sqrtd} {
+		if t <= 0 {
+			continue
+		}
+		normal := d.Multiply(t).Add(reflected.Negative()).Divide(fuzz)
+		cosine := math.Abs(vector.Dot(d, normal))
+		if cosine > 0 {
+			pdf += t * t / (4 * math.Pi * fuzz * fuzz * cosine)
+		}
+	}
+	return pdf
 }
 
 type Dielectric struct {
 	IR float64 //Refraction Index
+	// Tint, if set, filters the light passing or reflecting, white otherwise.
+	Tint texture.Texture
+}
+
+func (d *Dielectric) Scatter(rIn *ray.Ray, rec *HitRecord, s sampler.Sampler) (bool, *ray.Ray, vector.Color) {
+	return scatter(d, rIn, rec, s)
 }
 
-func (d *Dielectric) Scatter(rIn *ray.Ray, rec *HitRecord) (bool, *ray.Ray, vector.Color) {
+// Sample either reflects or refracts, with the probability of Schlick's reflectance.
+func (d *Dielectric) Sample(rIn *ray.Ray, rec *HitRecord, s sampler.Sampler) (BSDFSample, bool) {
 
-	attenuation := vector.Color{1, 1, 1}
+	attenuation := value(d.Tint, rec, vector.Color{1, 1, 1})
 	refractionRatio := d.IR
 	if rec.IsFrontFace {
 		refractionRatio = 1.0 / d.IR
@@ -82,7 +189,7 @@ func (d *Dielectric) Scatter(rIn *ray.Ray, rec *HitRecord) (bool, *ray.Ray, vect
 
 	cannotRefract := refractionRatio*sinTheta > 1.0
 
-	randFloat := randGen.Float64()
+	randFloat := s.Get1D()
 
 	if cannotRefract || d.reflectance(cosTheta, refractionRatio) > randFloat {
 		direction = vector.Reflect(unitDirection, rec.Normal)
@@ -90,13 +197,16 @@ func (d *Dielectric) Scatter(rIn *ray.Ray, rec *HitRecord) (bool, *ray.Ray, vect
 		direction = vector.Refract(unitDirection, rec.Normal, refractionRatio)
 	}
 
-	scattered := ray.Get()
-	scattered.Origin = rec.Point
-	scattered.Direction = direction
-	scattered.Time = rIn.Time
+	return BSDFSample{Direction: direction, Weight: attenuation, Specular: true}, true
+}
 
-	return true, scattered, attenuation
+// Eval is 0, both lobes are specular.
+func (d *Dielectric) Eval(rIn *ray.Ray, rec *HitRecord, direction vector.Vector) vector.Color {
+	return vector.Color{0, 0, 0}
+}
 
+func (d *Dielectric) Pdf(rIn *ray.Ray, rec *HitRecord, direction vector.Vector) float64 {
+	return 0
 }
 
 func (d *Dielectric) reflectance(cosine, refIdx float64) float64 {
@@ -105,3 +215,21 @@ func (d *Dielectric) reflectance(cosine, refIdx float64) float64 {
 	r0 = r0 * r0
 	return r0 + (1-r0)*math.Pow((1-cosine), 5)
 }
+
+// DiffuseLight emits the color of its texture evenly in all directions and
+// scatters nothing.
+type DiffuseLight struct {
+	Emit texture.Texture
+}
+
+func NewDiffuseLight(c vector.Color) *DiffuseLight {
+	return &DiffuseLight{Emit: texture.NewSolidColor(c)}
+}
+
+func (d *DiffuseLight) Scatter(rIn *ray.Ray, rec *HitRecord, s sampler.Sampler) (bool, *ray.Ray, vector.Color) {
+	return false, nil, vector.Color{0, 0, 0}
+}
+
+func (d *DiffuseLight) Emitted(u, v float64, p vector.Point) vector.Color {
+	return d.Emit.Value(u, v, p)
+}
@@ -0,0 +1,87 @@
+package hittable
+
+import (
+	"math"
+	"ray_tracing/ray"
+	"ray_tracing/sampler"
+	"ray_tracing/texture"
+	"ray_tracing/vector"
+	"testing"
+)
+
+func testHit() (*ray.Ray, *HitRecord) {
+	rIn := &ray.Ray{Origin: vector.Point{-1, 1, 0}, Direction: vector.Vector{1, -1, 0}}
+	rec := &HitRecord{Point: vector.Point{0, 0, 0}, Normal: vector.Vector{0, 1, 0}, IsFrontFace: true}
+	return rIn, rec
+}
+
+// TestBSDFPdfNormalized integrates Pdf over the sphere of directions and checks
+// that Sample's weight agrees with Eval / Pdf.
+func TestBSDFPdfNormalized(t *testing.T) {
+	albedo := vector.Color{0.5, 0.5, 0.5}
+	for name, b := range map[string]BSDF{
+		"lambertian": NewLambertian(albedo),
+		"metal":      NewMetal(albedo, 0.3),
+	} {
+		rIn, rec := testHit()
+		s := sampler.NewIndependent().Clone(1)
+
+		// A midpoint grid, random directions converge too slowly at the rim of
+		// the fuzz sphere, where the density of Metal is singular.
+		const n = 1000
+		integral := 0.0
+		for i := 0; i < n; i++ {
+			for j := 0; j < n; j++ {
+				d := vector.SampleUnitVector((float64(i)+0.5)/n, (float64(j)+0.5)/n)
+				integral += b.Pdf(rIn, rec, d) * 4 * math.Pi / (n * n)
+			}
+		}
+		if math.Abs(integral-1) > 0.02 {
+			t.Errorf("%s: Pdf integrates to %v", name, integral)
+		}
+
+		for i := 0; i < 100; i++ {
+			s.StartPixelSample(1, 0, i)
+			bs, ok := b.Sample(rIn, rec, s)
+			if !ok || bs.Specular {
+				continue
+			}
+			if math.Abs(bs.Pdf-b.Pdf(rIn, rec, bs.Direction)) > 1e-9 {
+				t.Fatalf("%s: sampled pdf %v differs from Pdf %v", name, bs.Pdf, b.Pdf(rIn, rec, bs.Direction))
+			}
+			want := b.Eval(rIn, rec, bs.Direction).Divide(bs.Pdf)
+			if diff := want.Add(bs.Weight.Negative()); !diff.IsCloseToZero() {
+				t.Fatalf("%s: weight %v, Eval / Pdf %v", name, bs.Weight, want)
+			}
+		}
+	}
+}
+
+func TestTexturedMaterials(t *testing.T) {
+	checker := texture.NewCheckerTexture(1,
+		texture.NewSolidColor(vector.Color{1, 0, 0}),
+		texture.NewSolidColor(vector.Color{0, 0, 1}))
+	rIn, rec := testHit()
+	s := sampler.NewIndependent().Clone(1)
+	s.StartPixelSample(0, 0, 0)
+
+	for _, p := range []vector.Point{{0.5, 0, 0.5}, {1.5, 0, 0.5}} {
+		rec.Point = p
+		bs, _ := (&Lambertian{Albedo: checker}).Sample(rIn, rec, s)
+		if want := checker.Value(rec.U, rec.V, p); bs.Weight != want {
+			t.Errorf("Lambertian at %v: weight %v, want %v", p, bs.Weight, want)
+		}
+	}
+
+	// A rough metal made smooth by its roughness texture is a mirror.
+	metal := NewMetal(vector.Color{1, 1, 1}, 0.5)
+	metal.Roughness = texture.NewSolidColor(vector.Color{0, 0, 0})
+	if bs, ok := metal.Sample(rIn, rec, s); !ok || !bs.Specular || bs.Direction != vector.Reflect(vector.UnitVector(rIn.Direction), rec.Normal) {
+		t.Errorf("smooth metal sampled %+v", bs)
+	}
+
+	glass := &Dielectric{IR: 1.5, Tint: texture.NewSolidColor(vector.Color{0.5, 1, 1})}
+	if bs, _ := glass.Sample(rIn, rec, s); bs.Weight != (vector.Color{0.5, 1, 1}) {
+		t.Errorf("tinted glass weight %v", bs.Weight)
+	}
+}
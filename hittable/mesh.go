@@ -0,0 +1,223 @@
+package hittable
+
+import (
+	"ray_tracing/interval"
+	"ray_tracing/ray"
+	"ray_tracing/vector"
+	"sort"
+)
+
+// TriangleMesh is a set of triangles sharing their vertex arrays, traced as a
+// single Hittable through a BVH of its own. It costs a few bytes per triangle
+// where a Triangle per face in a BVHNode tree costs a few hundred.
+type TriangleMesh struct {
+	Positions []vector.Point
+	Normals   []vector.Vector // per vertex, optional
+	UVs       [][2]float64    // per vertex, optional
+	// Indices holds three vertex indices per triangle, counter-clockwise.
+	Indices []uint32
+	// Materials are picked per triangle by FaceMaterials, or the first one is
+	// used for all. Triangles without one have a nil material.
+	Materials     []Material
+	FaceMaterials []uint16
+	nodes         []linearNode
+	order         []uint32 // triangle indices, in the order of the leaves
+}
+
+const meshLeafSize = 4
+
+// NewTriangleMesh builds the hierarchy over the triangles of indices, which
+// index positions three by three.
+func NewTriangleMesh(positions []vector.Point, indices []uint32, material Material) *TriangleMesh {
+	m := &TriangleMesh{
+		Positions: positions,
+		Indices:   indices,
+		Materials: []Material{material},
+		order:     make([]uint32, len(indices)/3),
+	}
+	centroids := make([]vector.Point, len(m.order))
+	for i := range m.order {
+		m.order[i] = uint32(i)
+		a, b, c := m.vertices(uint32(i))
+		centroids[i] = a.Add(b).Add(c).Divide(3)
+	}
+	m.nodes = make([]linearNode, 0, 2*len(m.order)/meshLeafSize+1)
+	if len(m.order) > 0 {
+		m.build(0, len(m.order), centroids)
+	}
+	return m
+}
+
+// SetNormals makes the mesh interpolate per vertex normals, parallel to Positions.
+func (m *TriangleMesh) SetNormals(normals []vector.Vector) {
+	m.Normals = normals
+}
+
+// SetUVs makes the mesh interpolate per vertex texture coordinates, parallel to Positions.
+func (m *TriangleMesh) SetUVs(uvs [][2]float64) {
+	m.UVs = uvs
+}
+
+// SetMaterials gives triangle i the material materials[faceMaterials[i]].
+func (m *TriangleMesh) SetMaterials(materials []Material, faceMaterials []uint16) {
+	m.Materials = materials
+	m.FaceMaterials = faceMaterials
+}
+
+func (m *TriangleMesh) Len() int {
+	return len(m.order)
+}
+
+func (m *TriangleMesh) vertices(i uint32) (vector.Point, vector.Point, vector.Point) {
+	return m.Positions[m.Indices[3*i]], m.Positions[m.Indices[3*i+1]], m.Positions[m.Indices[3*i+2]]
+}
+
+// material is nil, like the material of a Triangle made without one, when
+// the mesh has no materials or face i points past them.
+func (m *TriangleMesh) material(i uint32) Material {
+	k := 0
+	if int(i) < len(m.FaceMaterials) {
+		k = int(m.FaceMaterials[i])
+	}
+	if k >= len(m.Materials) {
+		return nil
+	}
+	return m.Materials[k]
+}
+
+// build appends the subtree over order[start:end], splitting at the median
+// centroid of the longest axis. Its leaves cover the triangles
+// order[offset:offset+count].
+func (m *TriangleMesh) build(start, end int, centroids []vector.Point) {
+	bbox := triangleBox(m.vertices(m.order[start]))
+	centers := interval.NewAABB(interval.FromPoints(centroids[m.order[start]], centroids[m.order[start]]))
+	for _, i := range m.order[start+1 : end] {
+		bbox = interval.CombineAABB(bbox, triangleBox(m.vertices(i)))
+		centers = interval.CombineAABB(centers, interval.NewAABB(interval.FromPoints(centroids[i], centroids[i])))
+	}
+
+	index := len(m.nodes)
+	m.nodes = append(m.nodes, linearNode{bbox: bbox})
+	if end-start <= meshLeafSize {
+		m.nodes[index].offset = uint32(start)
+		m.nodes[index].count = uint32(end - start)
+		return
+	}
+
+	axis := 0
+	for a := 1; a < 3; a++ {
+		if centers[a].Size() > centers[axis].Size() {
+			axis = a
+		}
+	}
+	tris := m.order[start:end]
+	sort.Slice(tris, func(i, j int) bool { return centroids[tris[i]][axis] < centroids[tris[j]][axis] })
+	middle := (start + end) / 2
+
+	m.nodes[index].axis = uint8(axis)
+	m.build(start, middle, centroids)
+	m.nodes[index].offset = uint32(len(m.nodes))
+	m.build(middle, end, centroids)
+}
+
+func (m *TriangleMesh) BoundingBox() interval.AABB {
+	if len(m.nodes) == 0 {
+		return interval.AABB{interval.Empty, interval.Empty, interval.Empty}
+	}
+	return m.nodes[0].bbox
+}
+
+func (m *TriangleMesh) Hit(r *ray.Ray, rayT interval.Interval, rec *HitRecord) bool {
+	t, i, b, ok := m.closestHit(r, rayT)
+	if ok {
+		m.setHit(r, rec, t, i, b)
+	}
+	return ok
+}
+
+// closestHit returns the ray parameter, triangle and barycentric coordinates
+// of the closest hit in rayT.
+func (m *TriangleMesh) closestHit(r *ray.Ray, rayT interval.Interval) (float64, uint32, [3]float64, bool) {
+	if len(m.nodes) == 0 {
+		return 0, 0, [3]float64{}, false
+	}
+	closest := rayT
+	hitTriangle := uint32(0)
+	var hitBarycentric [3]float64
+	hit := false
+
+	stack := make([]uint32, 0, 64)
+	node := uint32(0)
+	for {
+		n := &m.nodes[node]
+		if n.bbox.Hit(r, closest) {
+			if n.count > 0 {
+				for _, i := range m.order[n.offset : n.offset+n.count] {
+					a, b, c := m.vertices(i)
+					if t, bary, ok := intersectTriangle(r, closest, &[3]vector.Point{a, b, c}); ok {
+						closest[1] = t
+						hitTriangle, hitBarycentric, hit = i, bary, true
+					}
+				}
+			} else {
+				// Visit the child on the side the ray comes from first, its
+				// hits shorten the interval the other one is tested with.
+				near, far := node+1, n.offset
+				if r.Direction[n.axis] < 0 {
+					near, far = far, near
+				}
+				stack = append(stack, far)
+				node = near
+				continue
+			}
+		}
+		if len(stack) == 0 {
+			break
+		}
+		node = stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+	}
+
+	return closest[1], hitTriangle, hitBarycentric, hit
+}
+
+// setHit fills rec for a hit of triangle i, only done once for the closest one.
+func (m *TriangleMesh) setHit(r *ray.Ray, rec *HitRecord, t float64, i uint32, b [3]float64) {
+	ia, ib, ic := m.Indices[3*i], m.Indices[3*i+1], m.Indices[3*i+2]
+	a, pb, c := m.Positions[ia], m.Positions[ib], m.Positions[ic]
+
+	rec.T = t
+	rec.Point = r.At(t)
+	rec.SetFaceNormal(r, vector.UnitVector(vector.Cross(pb.Add(a.Negative()), c.Add(a.Negative()))))
+	if m.Normals != nil {
+		rec.Normal = shadingNormal(rec, b, &[3]vector.Vector{m.Normals[ia], m.Normals[ib], m.Normals[ic]})
+	}
+	uvs := barycentricUVs
+	if m.UVs != nil {
+		uvs = [3][2]float64{m.UVs[ia], m.UVs[ib], m.UVs[ic]}
+	}
+	rec.U, rec.V = interpolateUV(b, &uvs)
+	rec.DpDu, rec.DpDv = triangleDerivatives(&[3]vector.Point{a, pb, c}, &uvs)
+	rec.Tangent, rec.Bitangent = triangleTangents(rec.DpDu, rec.DpDv, rec.Normal)
+	rec.Material = m.material(i)
+}
+
+// Triangle returns a standalone copy of triangle i.
+func (m *TriangleMesh) Triangle(i int) *Triangle {
+	a, b, c := m.vertices(uint32(i))
+	t := NewTriangle(a, b, c, m.material(uint32(i)))
+	ia, ib, ic := m.Indices[3*i], m.Indices[3*i+1], m.Indices[3*i+2]
+	if m.Normals != nil {
+		t.SetNormals(m.Normals[ia], m.Normals[ib], m.Normals[ic])
+	}
+	if m.UVs != nil {
+		t.SetUVs(m.UVs[ia], m.UVs[ib], m.UVs[ic])
+	}
+	return t
+}
+
+// area weighs emissive faces for sampling.
+func (m *TriangleMesh) area(i int) float64 {
+	a, b, c := m.vertices(uint32(i))
+	return vector.Cross(b.Add(a.Negative()), c.Add(a.Negative())).Length() / 2
+}
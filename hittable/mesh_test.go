@@ -0,0 +1,134 @@
+package hittable
+
+import (
+	"math"
+	"ray_tracing/concrand"
+	"ray_tracing/interval"
+	"ray_tracing/ray"
+	"ray_tracing/vector"
+	"testing"
+
+	"golang.org/x/exp/rand"
+)
+
+// randomSoup returns n small random triangles in the cube [-1, 1]³.
+func randomSoup(rng *rand.Rand, n int) ([]vector.Point, []uint32) {
+	positions := make([]vector.Point, 0, 3*n)
+	indices := make([]uint32, 0, 3*n)
+	for i := 0; i < n; i++ {
+		center := vector.RandomBounded(rng, -1, 1)
+		for k := 0; k < 3; k++ {
+			indices = append(indices, uint32(len(positions)))
+			positions = append(positions, center.Add(vector.RandomBounded(rng, -0.1, 0.1)))
+		}
+	}
+	return positions, indices
+}
+
+// TestTriangleMeshMatchesTriangles checks the mesh's hierarchy against testing
+// every triangle.
+func TestTriangleMeshMatchesTriangles(t *testing.T) {
+	rng := concrand.New(1)
+	positions, indices := randomSoup(rng, 2000)
+	mesh := NewTriangleMesh(positions, indices, &Lambertian{})
+	world := NewWorld()
+	for i := 0; i < mesh.Len(); i++ {
+		world.Append(mesh.Triangle(i))
+	}
+
+	for i := 0; i < 2000; i++ {
+		r := &ray.Ray{Origin: vector.RandomBounded(rng, -2, 2), Direction: vector.RandomUnitVector(rng)}
+		var got, want HitRecord
+		hitGot := mesh.Hit(r, interval.Interval{0.001, math.Inf(1)}, &got)
+		hitWant := world.Hit(r, interval.Interval{0.001, math.Inf(1)}, &want)
+		if hitGot != hitWant || got.T != want.T || got.U != want.U || got.V != want.V || got.Normal != want.Normal {
+			t.Fatalf("ray %v: mesh hit %v at %v, triangles hit %v at %v", r, hitGot, got, hitWant, want)
+		}
+	}
+}
+
+// unitCubeMesh is the closed cube [0, 1]³, its bottom face is not emissive.
+func unitCubeMesh() *TriangleMesh {
+	positions := []vector.Point{
+		{0, 0, 0}, {1, 0, 0}, {1, 1, 0}, {0, 1, 0},
+		{0, 0, 1}, {1, 0, 1}, {1, 1, 1}, {0, 1, 1},
+	}
+	indices := []uint32{
+		0, 2, 1, 0, 3, 2, 4, 5, 6, 4, 6, 7, 0, 4, 7, 0, 7, 3,
+		1, 2, 6, 1, 6, 5, 3, 7, 6, 3, 6, 2, 0, 1, 5, 0, 5, 4,
+	}
+	m := NewTriangleMesh(positions, indices, nil)
+	m.SetMaterials([]Material{NewDiffuseLight(vector.Color{1, 1, 1}), NewLambertian(vector.Color{1, 1, 1})},
+		[]uint16{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 1})
+	return m
+}
+
+func TestMeshLightPdfNormalized(t *testing.T) {
+	lights := NewLights(NewInstance(unitCubeMesh(), vector.Translate(vector.Vector{-0.5, 2, -0.5})))
+	if lights.Len() != 1 {
+		t.Fatalf("the mesh makes %d lights", lights.Len())
+	}
+	// Rays through the cube cross two emissive faces, the pdf counts both.
+	origin := vector.Point{0.2, 0, 0.1}
+	const n = 1000
+	integral := 0.0
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			d := vector.SampleUnitVector((float64(i)+0.5)/n, (float64(j)+0.5)/n)
+			integral += lights.Pdf(origin, d, 0) * 4 * math.Pi / (n * n)
+		}
+	}
+	if math.Abs(integral-1) > 0.02 {
+		t.Errorf("Pdf integrates to %v", integral)
+	}
+}
+
+func TestMeshLightPicksFacesByArea(t *testing.T) {
+	// A small and a large triangle side by side, the large one is three
+	// times the area of the small one.
+	positions := []vector.Point{{0, 1, 0}, {1, 1, 0}, {0, 1, 1}, {1, 1, 0}, {4, 1, 0}, {1, 1, 1}}
+	m := NewTriangleMesh(positions, []uint32{0, 1, 2, 3, 4, 5}, NewDiffuseLight(vector.Color{1, 1, 1}))
+	light := newMeshLight(m)
+	origin := vector.Point{0.5, 0, 0.5}
+	const n = 100
+	large := 0
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			d := light.SampleDirection(origin, 0, (float64(i)+0.5)/n, (float64(j)+0.5)/n)
+			r := ray.Ray{Origin: origin, Direction: d}
+			_, face, _, ok := m.closestHit(&r, interval.Interval{0.001, math.Inf(1)})
+			if !ok {
+				t.Fatalf("sampled direction %v misses the mesh", d)
+			}
+			if face == 1 {
+				large++
+			}
+		}
+	}
+	if got := float64(large) / (n * n); math.Abs(got-0.75) > 0.01 {
+		t.Errorf("the large face got %v of the samples, want 0.75", got)
+	}
+}
+
+func TestTriangleMeshWithoutMaterials(t *testing.T) {
+	m := unitCubeMesh()
+	m.SetMaterials(nil, nil)
+	rec := HitRecord{}
+	r := ray.Ray{Origin: vector.Point{0.5, 0.5, -1}, Direction: vector.Vector{0, 0, 1}}
+	if !m.Hit(&r, interval.Interval{0.001, math.Inf(1)}, &rec) || rec.Material != nil {
+		t.Errorf("got material %v", rec.Material)
+	}
+	if n := NewLights(m).Len(); n != 0 {
+		t.Errorf("found %d lights", n)
+	}
+
+	// Faces past the materials get none either.
+	faces := make([]uint16, m.Len())
+	for i := range faces {
+		faces[i] = 3
+	}
+	m.SetMaterials([]Material{NewLambertian(vector.Color{1, 1, 1})}, faces)
+	if !m.Hit(&r, interval.Interval{0.001, math.Inf(1)}, &rec) || rec.Material != nil {
+		t.Errorf("got material %v", rec.Material)
+	}
+}
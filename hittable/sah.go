@@ -0,0 +1,311 @@
+package hittable
+
+import (
+	"ray_tracing/interval"
+	"ray_tracing/vector"
+	"runtime"
+	"sync"
+)
+
+// Costs of the surface area heuristic, relative to intersecting a primitive.
+const (
+	sahTraversalCost    = 1.0
+	sahIntersectionCost = 1.0
+	sahBins             = 16
+	// sahMaxLeafSize primitives are the most a leaf gets when the heuristic
+	// prefers not splitting, more only end up together if their centroids coincide.
+	sahMaxLeafSize = 4
+)
+
+// sahPrimitive caches what the builder needs of a Hittable.
+type sahPrimitive struct {
+	object   Hittable
+	bbox     interval.AABB
+	centroid vector.Point
+}
+
+// NewSAHTree builds the hierarchy by binning the primitives' centroids along
+// each axis and splitting where the surface area heuristic estimates the
+// lowest traversal cost. Leaves hold up to a few primitives in a Hittables.
+func NewSAHTree(src ...Hittable) *BVHNode {
+	return (&sahBuilder{}).tree(src)
+}
+
+// NewParallelSAHTree builds the same tree as NewSAHTree with up to GOMAXPROCS
+// goroutines: large nodes bin their primitives in chunks, and subtrees are
+// built concurrently.
+func NewParallelSAHTree(src ...Hittable) *BVHNode {
+	workers := runtime.GOMAXPROCS(0)
+	return (&sahBuilder{workers: workers, spare: make(chan struct{}, workers-1)}).tree(src)
+}
+
+// Nodes with fewer primitives than this are built by a single goroutine,
+// splitting them costs more than it saves.
+const sahParallelSize = 4096
+
+// sahBuilder holds the settings of a build, its zero value builds serially.
+type sahBuilder struct {
+	workers int
+	// spare holds a token per goroutine building a subtree besides the first.
+	spare chan struct{}
+}
+
+func (b *sahBuilder) tree(src []Hittable) *BVHNode {
+	prims := make([]sahPrimitive, len(src))
+	b.chunks(len(src), func(lo, hi int) {
+		for i := lo; i < hi; i++ {
+			prims[i] = newSAHPrimitive(src[i])
+		}
+	})
+	switch len(prims) {
+	case 0:
+		return &BVHNode{left: NewWorld(), right: NewWorld(), bbox: emptyAABB()}
+	case 1:
+		return &BVHNode{left: src[0], bbox: prims[0].bbox}
+	}
+
+	root, ok := b.build(prims).(*BVHNode)
+	if !ok {
+		// The heuristic made everything one leaf, the root still has to be a node.
+		middle := len(prims) / 2
+		left, right := sahLeaf(prims[:middle]), sahLeaf(prims[middle:])
+		root = &BVHNode{left: left, right: right, bbox: interval.CombineAABB(left.BoundingBox(), right.BoundingBox())}
+	}
+	return root
+}
+
+// chunks calls f on ranges covering [0, n), concurrently when the build is
+// parallel and n is large. The ranges do not depend on how many run at once.
+func (b *sahBuilder) chunks(n int, f func(lo, hi int)) {
+	if b.workers <= 1 || n < sahParallelSize {
+		f(0, n)
+		return
+	}
+	size := (n + b.workers - 1) / b.workers
+	wg := sync.WaitGroup{}
+	for lo := 0; lo < n; lo += size {
+		wg.Add(1)
+		go func(lo, hi int) {
+			f(lo, hi)
+			wg.Done()
+		}(lo, min(lo+size, n))
+	}
+	wg.Wait()
+}
+
+func newSAHPrimitive(o Hittable) sahPrimitive {
+	box := o.BoundingBox()
+	return sahPrimitive{
+		object:   o,
+		bbox:     box,
+		centroid: vector.Point{box[0].Min() + box[0].Size()/2, box[1].Min() + box[1].Size()/2, box[2].Min() + box[2].Size()/2},
+	}
+}
+
+func emptyAABB() interval.AABB {
+	return interval.AABB{interval.Empty, interval.Empty, interval.Empty}
+}
+
+func surfaceArea(box interval.AABB) float64 {
+	x, y, z := box[0].Size(), box[1].Size(), box[2].Size()
+	if x < 0 || y < 0 || z < 0 {
+		return 0
+	}
+	return 2 * (x*y + y*z + z*x)
+}
+
+// sahSplit is a candidate split of a node, primitives whose centroid falls in
+// a bin below bin go left.
+type sahSplit struct {
+	axis, bin int
+	cost      float64
+}
+
+// sahBins3 holds the primitive counts and bounds of the bins of every axis.
+type sahBins3 struct {
+	counts [3][sahBins]int
+	boxes  [3][sahBins]interval.AABB
+}
+
+func newSAHBins3() *sahBins3 {
+	bins := &sahBins3{}
+	for axis := range bins.boxes {
+		for i := range bins.boxes[axis] {
+			bins.boxes[axis][i] = emptyAABB()
+		}
+	}
+	return bins
+}
+
+func (bins *sahBins3) add(prims []sahPrimitive, centers interval.AABB) {
+	for i := range prims {
+		p := &prims[i]
+		for axis := 0; axis < 3; axis++ {
+			if centers[axis].Size() <= 0 {
+				continue
+			}
+			i := sahBin(p.centroid, centers, axis)
+			bins.counts[axis][i]++
+			bins.boxes[axis][i] = interval.CombineAABB(bins.boxes[axis][i], p.bbox)
+		}
+	}
+}
+
+func (bins *sahBins3) merge(other *sahBins3) {
+	for axis := range bins.counts {
+		for i := range bins.counts[axis] {
+			bins.counts[axis][i] += other.counts[axis][i]
+			bins.boxes[axis][i] = interval.CombineAABB(bins.boxes[axis][i], other.boxes[axis][i])
+		}
+	}
+}
+
+// bounds returns the box of prims and the box of their centroids.
+func (b *sahBuilder) bounds(prims []sahPrimitive) (interval.AABB, interval.AABB) {
+	mu := sync.Mutex{}
+	bbox, centers := emptyAABB(), emptyAABB()
+	b.chunks(len(prims), func(lo, hi int) {
+		chunkBox, chunkCenters := emptyAABB(), emptyAABB()
+		for i := range prims[lo:hi] {
+			p := &prims[lo+i]
+			chunkBox = interval.CombineAABB(chunkBox, p.bbox)
+			chunkCenters = interval.CombineAABB(chunkCenters, interval.AABB{
+				{p.centroid[0], p.centroid[0]}, {p.centroid[1], p.centroid[1]}, {p.centroid[2], p.centroid[2]},
+			})
+		}
+		mu.Lock()
+		bbox, centers = interval.CombineAABB(bbox, chunkBox), interval.CombineAABB(centers, chunkCenters)
+		mu.Unlock()
+	})
+	return bbox, centers
+}
+
+// bins sorts prims into the bins of every axis. Bounds and counts merge
+// exactly, so the result does not depend on the chunks.
+func (b *sahBuilder) bins(prims []sahPrimitive, centers interval.AABB) *sahBins3 {
+	bins := newSAHBins3()
+	if b.workers <= 1 || len(prims) < sahParallelSize {
+		bins.add(prims, centers)
+		return bins
+	}
+	mu := sync.Mutex{}
+	b.chunks(len(prims), func(lo, hi int) {
+		chunk := newSAHBins3()
+		chunk.add(prims[lo:hi], centers)
+		mu.Lock()
+		bins.merge(chunk)
+		mu.Unlock()
+	})
+	return bins
+}
+
+// build returns the subtree over prims, reordering them.
+func (b *sahBuilder) build(prims []sahPrimitive) Hittable {
+	if len(prims) == 1 {
+		return prims[0].object
+	}
+	bbox, centers := b.bounds(prims)
+
+	best, ok := bestSAHSplit(b.bins(prims, centers), bbox, centers)
+	leafCost := sahIntersectionCost * float64(len(prims))
+	middle := len(prims) / 2
+	switch {
+	case !ok:
+		// All centroids coincide, binning cannot separate them.
+		if len(prims) <= sahMaxLeafSize {
+			return sahLeaf(prims)
+		}
+	case len(prims) <= sahMaxLeafSize && leafCost <= best.cost:
+		return sahLeaf(prims)
+	default:
+		// Partition in place around the chosen bin.
+		middle = 0
+		for i := range prims {
+			if sahBin(prims[i].centroid, centers, best.axis) < best.bin {
+				prims[i], prims[middle] = prims[middle], prims[i]
+				middle++
+			}
+		}
+	}
+
+	var left, right Hittable
+	if b.spare != nil && len(prims) >= sahParallelSize {
+		select {
+		case b.spare <- struct{}{}:
+			done := make(chan struct{})
+			go func() {
+				left = b.build(prims[:middle])
+				<-b.spare
+				close(done)
+			}()
+			right = b.build(prims[middle:])
+			<-done
+			return newSAHNode(left, right)
+		default:
+			// Every goroutine is busy, build both here.
+		}
+	}
+	left, right = b.build(prims[:middle]), b.build(prims[middle:])
+	return newSAHNode(left, right)
+}
+
+// bestSAHSplit evaluates the splits between the bins of every axis. It fails
+// when no axis has a centroid extent to bin.
+func bestSAHSplit(bins *sahBins3, bbox, centers interval.AABB) (sahSplit, bool) {
+	best := sahSplit{cost: 0}
+	found := false
+	area := surfaceArea(bbox)
+	for axis := 0; axis < 3; axis++ {
+		if centers[axis].Size() <= 0 {
+			continue
+		}
+		counts, boxes := &bins.counts[axis], &bins.boxes[axis]
+
+		// Sweep from the right to get the cost of everything above each bin
+		// boundary, then from the left.
+		var rightArea [sahBins]float64
+		var rightCount [sahBins]int
+		box, count := emptyAABB(), 0
+		for b := sahBins - 1; b > 0; b-- {
+			box, count = interval.CombineAABB(box, boxes[b]), count+counts[b]
+			rightArea[b], rightCount[b] = surfaceArea(box), count
+		}
+		box, count = emptyAABB(), 0
+		for b := 1; b < sahBins; b++ {
+			box, count = interval.CombineAABB(box, boxes[b-1]), count+counts[b-1]
+			if count == 0 || rightCount[b] == 0 {
+				continue
+			}
+			cost := sahTraversalCost + sahIntersectionCost*
+				(float64(count)*surfaceArea(box)+float64(rightCount[b])*rightArea[b])/area
+			if !found || cost < best.cost {
+				best, found = sahSplit{axis: axis, bin: b, cost: cost}, true
+			}
+		}
+	}
+	return best, found
+}
+
+func sahBin(centroid vector.Point, centers interval.AABB, axis int) int {
+	b := int(sahBins * (centroid[axis] - centers[axis].Min()) / centers[axis].Size())
+	return min(max(b, 0), sahBins-1)
+}
+
+func sahLeaf(prims []sahPrimitive) Hittable {
+	if len(prims) == 1 {
+		return prims[0].object
+	}
+	leaf := NewWorld()
+	for _, p := range prims {
+		leaf.Append(p.object)
+	}
+	return leaf
+}
+
+func newSAHNode(left, right Hittable) *BVHNode {
+	return &BVHNode{
+		left:  left,
+		right: right,
+		bbox:  interval.CombineAABB(left.BoundingBox(), right.BoundingBox()),
+	}
+}
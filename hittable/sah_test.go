@@ -0,0 +1,97 @@
+package hittable
+
+import (
+	"math"
+	"ray_tracing/concrand"
+	"ray_tracing/interval"
+	"ray_tracing/ray"
+	"ray_tracing/vector"
+	"testing"
+
+	"golang.org/x/exp/rand"
+)
+
+// unevenScene has a dense cluster of small spheres and a few large ones far
+// away, a median split wastes most of its nodes on it.
+func unevenScene(rng *rand.Rand) []Hittable {
+	var objects []Hittable
+	for i := 0; i < 2000; i++ {
+		objects = append(objects, NewSphere(vector.RandomBounded(rng, -1, 1), 0.02, &Lambertian{}))
+	}
+	for i := 0; i < 20; i++ {
+		objects = append(objects, NewSphere(vector.RandomBounded(rng, -100, 100), 3, &Lambertian{}))
+	}
+	return objects
+}
+
+func TestSAHTree(t *testing.T) {
+	rng := concrand.New(5)
+	objects := unevenScene(rng)
+	sah := NewSAHTree(append([]Hittable(nil), objects...)...)
+	// The baseline is the random axis median split of NewBHVTree.
+	median := NewBHVTree(concrand.New(5), append([]Hittable(nil), objects...)...)
+	world := NewWorld(objects...)
+
+	sahStats, medianStats := sah.Stats(), median.Stats()
+	t.Logf("SAH: %v", sahStats)
+	t.Logf("median: %v", medianStats)
+	if sahStats.Primitives != len(objects) || medianStats.Primitives != len(objects) {
+		t.Errorf("trees hold %d and %d primitives, want %d", sahStats.Primitives, medianStats.Primitives, len(objects))
+	}
+	if sahStats.SAHCost >= medianStats.SAHCost {
+		t.Errorf("SAH tree costs %v, the median split one %v", sahStats.SAHCost, medianStats.SAHCost)
+	}
+	if sahStats.MaxLeafSize > sahMaxLeafSize {
+		t.Errorf("leaf of %d primitives", sahStats.MaxLeafSize)
+	}
+
+	for i := 0; i < 2000; i++ {
+		r := &ray.Ray{Origin: vector.RandomBounded(rng, -3, 3), Direction: vector.RandomUnitVector(rng)}
+		var got, want HitRecord
+		hitGot := sah.Hit(r, interval.Interval{0.001, math.Inf(1)}, &got)
+		hitWant := world.Hit(r, interval.Interval{0.001, math.Inf(1)}, &want)
+		if hitGot != hitWant || got.T != want.T {
+			t.Fatalf("ray %v: SAH tree hit %v at %v, list hit %v at %v", r, hitGot, got.T, hitWant, want.T)
+		}
+	}
+}
+
+func TestParallelSAHTree(t *testing.T) {
+	rng := concrand.New(9)
+	objects := append(unevenScene(rng), randomSpheres(rng, 20000)...)
+	serial := NewSAHTree(append([]Hittable(nil), objects...)...)
+	// More workers than this machine may have, so the concurrent paths run.
+	parallel := (&sahBuilder{workers: 8, spare: make(chan struct{}, 7)}).tree(append([]Hittable(nil), objects...))
+
+	if got, want := parallel.Stats(), serial.Stats(); got != want {
+		t.Errorf("parallel tree: %v, serial tree: %v", got, want)
+	}
+	for i := 0; i < 5000; i++ {
+		r := &ray.Ray{Origin: vector.RandomBounded(rng, -12, 12), Direction: vector.RandomUnitVector(rng)}
+		var got, want HitRecord
+		hitGot := parallel.Hit(r, interval.Interval{0.001, math.Inf(1)}, &got)
+		hitWant := serial.Hit(r, interval.Interval{0.001, math.Inf(1)}, &want)
+		if hitGot != hitWant || got.T != want.T || got.Point != want.Point {
+			t.Fatalf("ray %v: parallel tree hit %v at %v, serial tree hit %v at %v", r, hitGot, got.T, hitWant, want.T)
+		}
+	}
+}
+
+func BenchmarkSAHBuild(b *testing.B) {
+	objects := randomSpheres(concrand.New(13), 200000)
+	b.Run("median", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			NewBHVTree(concrand.New(13), append([]Hittable(nil), objects...)...)
+		}
+	})
+	b.Run("serial", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			NewSAHTree(append([]Hittable(nil), objects...)...)
+		}
+	})
+	b.Run("parallel", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			NewParallelSAHTree(append([]Hittable(nil), objects...)...)
+		}
+	})
+}
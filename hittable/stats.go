@@ -0,0 +1,63 @@
+package hittable
+
+import "fmt"
+
+// BVHStats describes the shape of a hierarchy. Nodes counts the inner nodes,
+// Leaves the children that are not BVHNodes. SAHCost is the traversal cost
+// the surface area heuristic estimates for a ray hitting the root box, with
+// the costs the SAH builder uses.
+type BVHStats struct {
+	Nodes, Leaves, Primitives int
+	MaxDepth                  int
+	MinLeafSize, MaxLeafSize  int
+	SAHCost                   float64
+}
+
+func (s BVHStats) MeanLeafSize() float64 {
+	if s.Leaves == 0 {
+		return 0
+	}
+	return float64(s.Primitives) / float64(s.Leaves)
+}
+
+func (s BVHStats) String() string {
+	return fmt.Sprintf("%d inner nodes, %d leaves of %d to %d (mean %.2f) primitives, depth %d, SAH cost %.2f",
+		s.Nodes, s.Leaves, s.MinLeafSize, s.MaxLeafSize, s.MeanLeafSize(), s.MaxDepth, s.SAHCost)
+}
+
+// Stats walks the tree, a Hittables leaf counts its objects.
+func (b *BVHNode) Stats() BVHStats {
+	s := BVHStats{MinLeafSize: -1}
+	rootArea := surfaceArea(b.bbox)
+	var walk func(h Hittable, depth int)
+	walk = func(h Hittable, depth int) {
+		s.MaxDepth = max(s.MaxDepth, depth)
+		weight := 0.0
+		if rootArea > 0 {
+			weight = surfaceArea(h.BoundingBox()) / rootArea
+		}
+		node, ok := h.(*BVHNode)
+		if !ok {
+			size := 1
+			if list, ok := h.(*Hittables); ok {
+				size = len(list.objects)
+			}
+			s.Leaves++
+			s.Primitives += size
+			s.MaxLeafSize = max(s.MaxLeafSize, size)
+			if s.MinLeafSize < 0 || size < s.MinLeafSize {
+				s.MinLeafSize = size
+			}
+			s.SAHCost += sahIntersectionCost * float64(size) * weight
+			return
+		}
+		s.Nodes++
+		s.SAHCost += sahTraversalCost * weight
+		walk(node.left, depth+1)
+		if node.right != nil {
+			walk(node.right, depth+1)
+		}
+	}
+	walk(b, 0)
+	return s
+}
@@ -0,0 +1,202 @@
+package hittable
+
+import (
+	"math"
+	"ray_tracing/interval"
+	"ray_tracing/ray"
+	"ray_tracing/vector"
+)
+
+// Triangle is a flat triangle, optionally smooth shaded with per-vertex
+// normals and textured with per-vertex UVs.
+type Triangle struct {
+	Vertices [3]vector.Point
+	Material Material
+	normals  *[3]vector.Vector
+	uvs      *[3][2]float64
+	normal   vector.Vector // unit geometric normal, on the counter-clockwise side
+	area     float64
+	bbox     interval.AABB
+}
+
+func NewTriangle(a, b, c vector.Point, material Material) *Triangle {
+	n := vector.Cross(b.Add(a.Negative()), c.Add(a.Negative()))
+	return &Triangle{
+		Vertices: [3]vector.Point{a, b, c},
+		Material: material,
+		normal:   vector.UnitVector(n),
+		area:     n.Length() / 2,
+		bbox:     triangleBox(a, b, c),
+	}
+}
+
+// SetNormals makes the triangle interpolate the shading normal between the
+// given vertex normals.
+func (t *Triangle) SetNormals(a, b, c vector.Vector) {
+	t.normals = &[3]vector.Vector{a, b, c}
+}
+
+// SetUVs makes the triangle interpolate the texture coordinates between the
+// given vertex UVs. Without them, U and V are the barycentric coordinates of
+// the second and third vertex.
+func (t *Triangle) SetUVs(a, b, c [2]float64) {
+	t.uvs = &[3][2]float64{a, b, c}
+}
+
+func (t *Triangle) BoundingBox() interval.AABB {
+	return t.bbox
+}
+
+func (t *Triangle) Hit(r *ray.Ray, rayT interval.Interval, rec *HitRecord) bool {
+	root, b, ok := intersectTriangle(r, rayT, &t.Vertices)
+	if !ok {
+		return false
+	}
+	rec.T = root
+	rec.Point = r.At(rec.T)
+	rec.SetFaceNormal(r, t.normal)
+	if t.normals != nil {
+		rec.Normal = shadingNormal(rec, b, t.normals)
+	}
+	uvs := &barycentricUVs
+	if t.uvs != nil {
+		uvs = t.uvs
+	}
+	rec.U, rec.V = interpolateUV(b, uvs)
+	rec.DpDu, rec.DpDv = triangleDerivatives(&t.Vertices, uvs)
+	rec.Tangent, rec.Bitangent = triangleTangents(rec.DpDu, rec.DpDv, rec.Normal)
+	rec.Material = t.Material
+	return true
+}
+
+// SampleDirection picks a point uniformly on the triangle's area.
+func (t *Triangle) SampleDirection(origin vector.Point, time, u, v float64) vector.Vector {
+	su := math.Sqrt(u)
+	b0, b1 := 1-su, v*su
+	p := t.Vertices[0].Multiply(b0).
+		Add(t.Vertices[1].Multiply(b1)).
+		Add(t.Vertices[2].Multiply(1 - b0 - b1))
+	return p.Add(origin.Negative())
+}
+
+// PdfValue converts the uniform area density to solid angle. Both faces are sampled.
+func (t *Triangle) PdfValue(origin vector.Point, direction vector.Vector, time float64) float64 {
+	r := ray.Ray{Origin: origin, Direction: direction, Time: time}
+	rec := HitRecord{}
+	if t.area == 0 || !t.Hit(&r, interval.Interval{0.001, math.Inf(1)}, &rec) {
+		return 0
+	}
+	distanceSquared := rec.T * rec.T * direction.LengthSquared()
+	cosine := math.Abs(vector.Dot(vector.UnitVector(direction), t.normal))
+	if cosine == 0 {
+		return 0
+	}
+	return distanceSquared / (cosine * t.area)
+}
+
+func triangleBox(a, b, c vector.Point) interval.AABB {
+	return interval.CombineAABB(
+		interval.NewAABB(interval.FromPoints(a, b)),
+		interval.NewAABB(interval.FromPoints(c, c)),
+	).Pad(0.0001)
+}
+
+// intersectTriangle is the watertight test of Woop, Benthin and Wald
+// ("Watertight Ray/Triangle Intersection", 2013). The vertices are moved into
+// a space where the ray starts at the origin and runs along +z, there the edge
+// functions are evaluated the same way for both triangles sharing an edge, so
+// no ray slips between them. It returns the ray parameter and the barycentric
+// coordinates of the hit.
+func intersectTriangle(r *ray.Ray, rayT interval.Interval, p *[3]vector.Point) (float64, [3]float64, bool) {
+	d := r.Direction
+	kz := 0
+	if math.Abs(d[1]) > math.Abs(d[kz]) {
+		kz = 1
+	}
+	if math.Abs(d[2]) > math.Abs(d[kz]) {
+		kz = 2
+	}
+	kx := (kz + 1) % 3
+	ky := (kx + 1) % 3
+	if d[kz] == 0 {
+		return 0, [3]float64{}, false
+	}
+
+	sz := 1 / d[kz]
+	sx := d[kx] * sz
+	sy := d[ky] * sz
+
+	a := p[0].Add(r.Origin.Negative())
+	b := p[1].Add(r.Origin.Negative())
+	c := p[2].Add(r.Origin.Negative())
+	ax, ay := a[kx]-sx*a[kz], a[ky]-sy*a[kz]
+	bx, by := b[kx]-sx*b[kz], b[ky]-sy*b[kz]
+	cx, cy := c[kx]-sx*c[kz], c[ky]-sy*c[kz]
+
+	u := cx*by - cy*bx
+	v := ax*cy - ay*cx
+	w := bx*ay - by*ax
+	if (u < 0 || v < 0 || w < 0) && (u > 0 || v > 0 || w > 0) {
+		return 0, [3]float64{}, false
+	}
+	det := u + v + w
+	if det == 0 {
+		return 0, [3]float64{}, false
+	}
+
+	t := (u*a[kz] + v*b[kz] + w*c[kz]) * sz / det
+	if !rayT.Surrounds(t) {
+		return 0, [3]float64{}, false
+	}
+	return t, [3]float64{u / det, v / det, w / det}, true
+}
+
+// shadingNormal interpolates normals with the barycentric coordinates b and
+// turns the result to the side rec.Normal, the geometric normal, is on.
+func shadingNormal(rec *HitRecord, b [3]float64, normals *[3]vector.Vector) vector.Vector {
+	n := vector.UnitVector(normals[0].Multiply(b[0]).
+		Add(normals[1].Multiply(b[1])).
+		Add(normals[2].Multiply(b[2])))
+	if vector.Dot(n, rec.Normal) < 0 {
+		return n.Negative()
+	}
+	return n
+}
+
+// barycentricUVs make U and V the barycentric coordinates of the second and
+// third vertex, for triangles without texture coordinates.
+var barycentricUVs = [3][2]float64{{0, 0}, {1, 0}, {0, 1}}
+
+// triangleDerivatives solves for how the point moves with U and V over the
+// triangle, both are zero when the UVs are degenerate.
+func triangleDerivatives(p *[3]vector.Point, uvs *[3][2]float64) (vector.Vector, vector.Vector) {
+	e1, e2 := p[1].Add(p[0].Negative()), p[2].Add(p[0].Negative())
+	du1, dv1 := uvs[1][0]-uvs[0][0], uvs[1][1]-uvs[0][1]
+	du2, dv2 := uvs[2][0]-uvs[0][0], uvs[2][1]-uvs[0][1]
+	det := du1*dv2 - du2*dv1
+	if det == 0 {
+		return vector.Vector{}, vector.Vector{}
+	}
+	return e1.Multiply(dv2).Add(e2.Multiply(-dv1)).Divide(det),
+		e2.Multiply(du1).Add(e1.Multiply(-du2)).Divide(det)
+}
+
+// triangleTangents makes the derivatives unit vectors perpendicular to normal.
+func triangleTangents(dpdu, dpdv, normal vector.Vector) (vector.Vector, vector.Vector) {
+	tangent := dpdu.Add(normal.Multiply(-vector.Dot(normal, dpdu)))
+	if tangent.LengthSquared() == 0 {
+		return vector.OrthonormalBasis(normal)
+	}
+	tangent = vector.UnitVector(tangent)
+	bitangent := dpdv.Add(normal.Multiply(-vector.Dot(normal, dpdv))).
+		Add(tangent.Multiply(-vector.Dot(tangent, dpdv)))
+	if bitangent.LengthSquared() == 0 {
+		return tangent, vector.Cross(normal, tangent)
+	}
+	return tangent, vector.UnitVector(bitangent)
+}
+
+func interpolateUV(b [3]float64, uvs *[3][2]float64) (float64, float64) {
+	return b[0]*uvs[0][0] + b[1]*uvs[1][0] + b[2]*uvs[2][0],
+		b[0]*uvs[0][1] + b[1]*uvs[1][1] + b[2]*uvs[2][1]
+}
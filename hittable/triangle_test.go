@@ -0,0 +1,92 @@
+package hittable
+
+import (
+	"math"
+	"ray_tracing/interval"
+	"ray_tracing/ray"
+	"ray_tracing/vector"
+	"testing"
+)
+
+func TestTriangleHit(t *testing.T) {
+	tri := NewTriangle(vector.Point{0, 0, 0}, vector.Point{1, 0, 0}, vector.Point{0, 1, 0}, nil)
+	r := &ray.Ray{Origin: vector.Point{0.25, 0.5, 2}, Direction: vector.Vector{0, 0, -1}}
+	rec := HitRecord{}
+	if !tri.Hit(r, interval.Interval{0.001, math.Inf(1)}, &rec) {
+		t.Fatal("ray through the triangle missed")
+	}
+	if math.Abs(rec.T-2) > 1e-12 || math.Abs(rec.U-0.25) > 1e-12 || math.Abs(rec.V-0.5) > 1e-12 {
+		t.Errorf("got T %v, U %v, V %v, want 2, 0.25, 0.5", rec.T, rec.U, rec.V)
+	}
+	if !rec.IsFrontFace || rec.Normal != (vector.Vector{0, 0, 1}) {
+		t.Errorf("got normal %v, front face %v", rec.Normal, rec.IsFrontFace)
+	}
+
+	tri.SetUVs([2]float64{0, 0}, [2]float64{2, 0}, [2]float64{0, 4})
+	tri.SetNormals(vector.Vector{0, 0, 1}, vector.Vector{1, 0, 0}, vector.Vector{0, 0, 1})
+	tri.Hit(r, interval.Interval{0.001, math.Inf(1)}, &rec)
+	if math.Abs(rec.U-0.5) > 1e-12 || math.Abs(rec.V-2) > 1e-12 {
+		t.Errorf("got interpolated U %v, V %v, want 0.5, 2", rec.U, rec.V)
+	}
+	if want := vector.UnitVector(vector.Vector{0.25, 0, 0.75}); vector.Dot(rec.Normal, want) < 1-1e-12 {
+		t.Errorf("got shading normal %v, want %v", rec.Normal, want)
+	}
+
+	if tri.Hit(&ray.Ray{Origin: vector.Point{0.75, 0.75, 2}, Direction: vector.Vector{0, 0, -1}}, interval.Interval{0.001, math.Inf(1)}, &rec) {
+		t.Error("ray beside the triangle hit")
+	}
+}
+
+// TestTriangleWatertight shoots rays at the shared edges and vertex of a fan,
+// every one of them has to hit at least one triangle.
+func TestTriangleWatertight(t *testing.T) {
+	center := vector.Point{0.1234567, -0.2345678, 0.3456789}
+	const n = 7
+	var fan []*Triangle
+	rim := func(k int) vector.Point {
+		k %= n
+		phi := 2 * math.Pi * float64(k) / n
+		return vector.Point{math.Cos(phi) + 0.1*float64(k), math.Sin(phi), 0.3 * math.Sin(3*phi)}
+	}
+	for k := 0; k < n; k++ {
+		fan = append(fan, NewTriangle(center, rim(k), rim(k+1), nil))
+	}
+	origin := vector.Point{0.3141592, 0.2718281, 5.1}
+	for k := 0; k < n; k++ {
+		for i := 0; i <= 100; i++ {
+			f := float64(i) / 100
+			target := center.Multiply(1 - f).Add(rim(k).Multiply(f))
+			r := &ray.Ray{Origin: origin, Direction: target.Add(origin.Negative())}
+			hit := false
+			for _, tri := range fan {
+				rec := HitRecord{}
+				hit = hit || tri.Hit(r, interval.Interval{0.001, math.Inf(1)}, &rec)
+			}
+			if !hit {
+				t.Fatalf("ray towards edge %d at %v leaked through", k, f)
+			}
+		}
+	}
+}
+
+func TestTrianglePdfNormalized(t *testing.T) {
+	tri := NewTriangle(vector.Point{-1, 1, -1}, vector.Point{2, 1.5, 0}, vector.Point{0, 0.5, 2}, nil)
+	origin := vector.Point{0, 0, 0}
+	const n = 1000
+	integral := 0.0
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			d := vector.SampleUnitVector((float64(i)+0.5)/n, (float64(j)+0.5)/n)
+			integral += tri.PdfValue(origin, d, 0) * 4 * math.Pi / (n * n)
+		}
+	}
+	if math.Abs(integral-1) > 0.01 {
+		t.Errorf("PdfValue integrates to %v", integral)
+	}
+	for i := 0; i < 100; i++ {
+		d := tri.SampleDirection(origin, 0, float64(i%10)/10+0.05, float64(i/10)/10+0.05)
+		if tri.PdfValue(origin, d, 0) == 0 {
+			t.Fatalf("sampled direction %v misses the triangle", d)
+		}
+	}
+}
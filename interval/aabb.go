@@ -91,3 +91,14 @@ func (a *AABB) Hit(rIn *ray.Ray, rayT Interval) bool {
 	}
 	return true
 }
+
+// Pad widens every axis thinner than delta to delta, so that flat shapes such
+// as axis aligned triangles still have a box rays can hit.
+func (a AABB) Pad(delta float64) AABB {
+	for i := range a {
+		if a[i].Size() < delta {
+			a[i] = a[i].Expand(delta)
+		}
+	}
+	return a
+}
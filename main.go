@@ -1,9 +1,10 @@
 package main
 
 import (
+	"log"
 	"math"
-	"math/rand"
 	"ray_tracing/camera"
+	"ray_tracing/concrand"
 	"ray_tracing/hittable"
 	"ray_tracing/texture"
 	"ray_tracing/vector"
@@ -12,11 +13,11 @@ import (
 
 func Scene1() {
 	// World
-	materialGround := hittable.Lambertian{Albedo: vector.Color{0.8, 0.8, 0.0}}
-	materialCenter := hittable.Lambertian{Albedo: vector.Color{0.1, 0.2, 0.5}}
-	//materialLeft := hittable.Metal{Albedo: vector.Color{1, 1, 1}, Fuzziness: 0.2}
-	materialLeft := hittable.Dielectric{1.5}
-	materialRight := hittable.Metal{Albedo: vector.Color{0.8, 0.6, 0.2}, Fuzziness: 0.0}
+	materialGround := hittable.Lambertian{Albedo: texture.NewSolidColor(vector.Color{0.8, 0.8, 0.0})}
+	materialCenter := hittable.Lambertian{Albedo: texture.NewSolidColor(vector.Color{0.1, 0.2, 0.5})}
+	//materialLeft := hittable.Metal{Albedo: texture.NewSolidColor(vector.Color{1, 1, 1}), Fuzziness: 0.2}
+	materialLeft := hittable.Dielectric{IR: 1.5}
+	materialRight := hittable.Metal{Albedo: texture.NewSolidColor(vector.Color{0.8, 0.6, 0.2}), Fuzziness: 0.0}
 
 	world := hittable.NewWorld(
 		hittable.NewSphere(
@@ -52,13 +53,16 @@ func Scene1() {
 		),
 		camera.WithFocus(10.0, 3.4),
 		camera.WithImageWidth(600),
+		camera.WithWorkers(12),
 	)
-	c.Render("test_ray.ppm", world, 12)
+	if err := c.Render("test_ray.ppm", world); err != nil {
+		log.Fatal(err)
+	}
 }
 
 func Scene2() {
-	materialLeft := hittable.Lambertian{Albedo: vector.Color{0, 0, 1}}
-	materialRight := hittable.Lambertian{Albedo: vector.Color{1, 0, 0}}
+	materialLeft := hittable.Lambertian{Albedo: texture.NewSolidColor(vector.Color{0, 0, 1})}
+	materialRight := hittable.Lambertian{Albedo: texture.NewSolidColor(vector.Color{1, 0, 0})}
 
 	r := math.Cos(math.Pi / 4)
 
@@ -74,19 +78,22 @@ func Scene2() {
 	)
 
 	c := camera.Camera{}
-	c.Init(camera.WithImageWidth(500))
-	c.Render("test_ray.ppm", world, 16)
+	c.Init(camera.WithImageWidth(500), camera.WithWorkers(16))
+	if err := c.Render("test_ray.ppm", world); err != nil {
+		log.Fatal(err)
+	}
 }
 
 func Scene3() {
-	// World
-	materialGround := hittable.Lambertian{Albedo: vector.Color{0.5, 0.5, 0.5}}
+	rng := concrand.New(2023)
 
+	// World
 	checker := texture.NewCheckerTexture(
 		0.32,
 		texture.NewSolidColor(vector.Color{.2, .3, .1}),
 		texture.NewSolidColor(vector.Color{.9, .9, .9}),
 	)
+	materialGround := hittable.Lambertian{Albedo: checker}
 
 	world := hittable.NewWorld(
 		hittable.NewSphere(
@@ -97,26 +104,26 @@ func Scene3() {
 
 	for a := -11; a < 11; a++ {
 		for b := -11; b < 11; b++ {
-			chooseMaterial := rand.Float64()
-			center := vector.Point{float64(a) + 0.9*rand.Float64(), 0.2, float64(b) + 0.9*rand.Float64()}
+			chooseMaterial := rng.Float64()
+			center := vector.Point{float64(a) + 0.9*rng.Float64(), 0.2, float64(b) + 0.9*rng.Float64()}
 			if center.Add(vector.Point{4, 0.2, 0}.Negative()).Length() > 0.9 {
 				var sphereMaterial hittable.Material
 
 				if chooseMaterial < 0.8 {
 					//diffuse
-					albedo := vector.Multiply(vector.Random(), vector.Random())
-					sphereMaterial = &hittable.Lambertian{Albedo: albedo}
+					albedo := vector.Multiply(vector.Random(rng), vector.Random(rng))
+					sphereMaterial = hittable.NewLambertian(albedo)
 				} else if chooseMaterial < 0.95 {
 					//metal
-					albedo := vector.RandomBounded(0.5, 1)
-					fuzz := rand.Float64() / 2
-					sphereMaterial = &hittable.Metal{Albedo: albedo, Fuzziness: fuzz}
+					albedo := vector.RandomBounded(rng, 0.5, 1)
+					fuzz := rng.Float64() / 2
+					sphereMaterial = hittable.NewMetal(albedo, fuzz)
 				} else {
 					// glass
 					sphereMaterial = &hittable.Dielectric{IR: 1.5}
 				}
 				lilSphere := hittable.NewSphere(center, 0.2, sphereMaterial)
-				lilSphere.MoveTo(center.Add(vector.RandomBounded(0.0, 0.5)))
+				lilSphere.MoveTo(center.Add(vector.RandomBounded(rng, 0.0, 0.5)))
 				world.Append(lilSphere)
 			}
 		}
@@ -132,13 +139,13 @@ func Scene3() {
 		hittable.NewSphere(
 			vector.Point{-4, 1, 0},
 			1.0,
-			&hittable.Lambertian{Albedo: vector.Color{0.4, 0.2, 0.1}},
+			hittable.NewLambertian(vector.Color{0.4, 0.2, 0.1}),
 		),
 
 		hittable.NewSphere(
 			vector.Point{4, 1, 0},
 			1.0,
-			&hittable.Metal{Albedo: vector.Color{0.7, 0.6, 0.5}, Fuzziness: 0},
+			hittable.NewMetal(vector.Color{0.7, 0.6, 0.5}, 0),
 		),
 	)
 
@@ -153,8 +160,147 @@ func Scene3() {
 		camera.WithImageWidth(800),
 		camera.WithSamplesPerPixel(100),
 		camera.WithMaxRayDepth(50),
+		camera.WithWorkers(12),
+	)
+	if err := c.Render("test_ray.ppm", world.ToLinearBVH()); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func Scene4() {
+	// Emissive spheres in the dark, lighting a diffuse ground and a glass ball.
+	world := hittable.NewWorld(
+		hittable.NewSphere(
+			vector.Point{0, -1000, 0},
+			1000,
+			hittable.NewLambertian(vector.Color{0.6, 0.6, 0.6})),
+		hittable.NewSphere(
+			vector.Point{0, 2, 0},
+			2,
+			&hittable.Dielectric{IR: 1.5}),
+		hittable.NewSphere(
+			vector.Point{0, 7, 0},
+			1.5,
+			hittable.NewDiffuseLight(vector.Color{4, 4, 4})),
+		hittable.NewSphere(
+			vector.Point{4, 1, 3},
+			0.5,
+			hittable.NewDiffuseLight(vector.Color{8, 2, 1})),
 	)
-	c.Render("test_ray.ppm", world.ToBVHTree(), 12)
+
+	c := camera.Camera{}
+	c.Init(
+		camera.WithVFOV(20),
+		camera.WithPosition(vector.Vector{0, 1, 0},
+			vector.Vector{26, 3, 6},
+			vector.Vector{0, 2, 0},
+		),
+		camera.WithImageWidth(800),
+		camera.WithSamplesPerPixel(200),
+		camera.WithBackground(camera.NoBackground),
+	)
+	if err := c.Render("test_ray.ppm", world); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// quad splits the parallelogram with corner q and edges u and v into two triangles.
+func quad(q, u, v vector.Point, material hittable.Material) []hittable.Hittable {
+	return []hittable.Hittable{
+		hittable.NewTriangle(q, q.Add(u), q.Add(u).Add(v), material),
+		hittable.NewTriangle(q, q.Add(u).Add(v), q.Add(v), material),
+	}
+}
+
+// unitCube is a mesh of the cube [0, 1]³.
+func unitCube(material hittable.Material) *hittable.TriangleMesh {
+	positions := []vector.Point{
+		{0, 0, 0}, {1, 0, 0}, {1, 1, 0}, {0, 1, 0},
+		{0, 0, 1}, {1, 0, 1}, {1, 1, 1}, {0, 1, 1},
+	}
+	indices := []uint32{
+		0, 2, 1, 0, 3, 2, // front
+		4, 5, 6, 4, 6, 7, // back
+		0, 4, 7, 0, 7, 3, // left
+		1, 2, 6, 1, 6, 5, // right
+		0, 1, 5, 0, 5, 4, // bottom
+		3, 7, 6, 3, 6, 2, // top
+	}
+	return hittable.NewTriangleMesh(positions, indices, material)
+}
+
+func Scene5() {
+	// Cornell box, every wall is made of triangles.
+	red := hittable.NewLambertian(vector.Color{0.65, 0.05, 0.05})
+	white := hittable.NewLambertian(vector.Color{0.73, 0.73, 0.73})
+	green := hittable.NewLambertian(vector.Color{0.12, 0.45, 0.15})
+	light := hittable.NewDiffuseLight(vector.Color{15, 15, 15})
+
+	world := hittable.NewWorld()
+	world.Append(quad(vector.Point{555, 0, 0}, vector.Point{0, 555, 0}, vector.Point{0, 0, 555}, green)...)
+	world.Append(quad(vector.Point{0, 0, 0}, vector.Point{0, 555, 0}, vector.Point{0, 0, 555}, red)...)
+	world.Append(quad(vector.Point{343, 554, 332}, vector.Point{-130, 0, 0}, vector.Point{0, 0, -105}, light)...)
+	world.Append(quad(vector.Point{0, 0, 0}, vector.Point{555, 0, 0}, vector.Point{0, 0, 555}, white)...)
+	world.Append(quad(vector.Point{555, 555, 555}, vector.Point{-555, 0, 0}, vector.Point{0, 0, -555}, white)...)
+	world.Append(quad(vector.Point{0, 0, 555}, vector.Point{555, 0, 0}, vector.Point{0, 555, 0}, white)...)
+
+	// Both boxes are instances of the same cube.
+	cube := unitCube(white)
+	world.Append(
+		hittable.NewInstance(cube, vector.Scale(165, 330, 165).
+			Then(vector.RotateY(15)).
+			Then(vector.Translate(vector.Vector{265, 0, 295}))),
+		hittable.NewInstance(cube, vector.Scale(165, 165, 165).
+			Then(vector.RotateY(-18)).
+			Then(vector.Translate(vector.Vector{130, 0, 65}))),
+		hittable.NewSphere(vector.Point{190, 235, 170}, 70, &hittable.Dielectric{IR: 1.5}),
+	)
+
+	c := camera.Camera{}
+	c.Init(
+		camera.WithAspectRatio(1),
+		camera.WithVFOV(40),
+		camera.WithPosition(vector.Vector{0, 1, 0},
+			vector.Vector{278, 278, -800},
+			vector.Vector{278, 278, 0},
+		),
+		camera.WithImageWidth(600),
+		camera.WithSamplesPerPixel(200),
+		camera.WithBackground(camera.NoBackground),
+		camera.WithLightSampling(true),
+	)
+	if err := c.Render("test_ray.ppm", world.ToLinearBVH()); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func Scene6() {
+	// Procedural textures: marble, wood and granite balls on a checker floor.
+	checker := texture.NewCheckerTexture(
+		1,
+		texture.NewSolidColor(vector.Color{.2, .3, .1}),
+		texture.NewSolidColor(vector.Color{.9, .9, .9}),
+	)
+	world := hittable.NewWorld(
+		hittable.NewSphere(vector.Point{0, -1000, 0}, 1000, &hittable.Lambertian{Albedo: checker}),
+		hittable.NewSphere(vector.Point{-2.2, 1, 0}, 1, &hittable.Lambertian{Albedo: texture.NewMarbleTexture(1, 4)}),
+		hittable.NewSphere(vector.Point{0, 1, 0}, 1, &hittable.Lambertian{Albedo: texture.NewWoodTexture(2, 6)}),
+		hittable.NewSphere(vector.Point{2.2, 1, 0}, 1, &hittable.Lambertian{Albedo: texture.NewGraniteTexture(3, 8)}),
+	)
+
+	c := camera.Camera{}
+	c.Init(
+		camera.WithVFOV(30),
+		camera.WithPosition(vector.Vector{0, 1, 0},
+			vector.Vector{0, 3, 10},
+			vector.Vector{0, 1, 0},
+		),
+		camera.WithImageWidth(800),
+		camera.WithSamplesPerPixel(100),
+	)
+	if err := c.Render("test_ray.ppm", world); err != nil {
+		log.Fatal(err)
+	}
 }
 
 func main() {
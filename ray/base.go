@@ -17,6 +17,11 @@ type Ray struct {
 	Origin    vector.Point
 	Direction vector.Vector
 	Time      float64
+	// Camera rays also carry the rays through the next pixel to the right (X)
+	// and below (Y), how far apart they land gives textures a footprint.
+	HasDifferentials         bool
+	RxOrigin, RyOrigin       vector.Point
+	RxDirection, RyDirection vector.Vector
 }
 
 func Get() *Ray {
@@ -28,6 +33,7 @@ func Get() *Ray {
 func Put(r *Ray) {
 	r.Origin = vector.Vector{0, 0, 0}
 	r.Direction = vector.Vector{0, 0, 0}
+	r.HasDifferentials = false
 	pool.Put(r)
 }
 
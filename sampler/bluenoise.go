@@ -0,0 +1,176 @@
+package sampler
+
+import (
+	"math"
+	"ray_tracing/concrand"
+	"sync"
+)
+
+// BlueNoise shares one Owen scrambled Sobol sequence between all pixels and
+// rotates it per pixel by the values of a blue noise mask (Cranley-Patterson
+// rotation). Neighbouring pixels then err in opposite directions, which leaves
+// high frequency noise that is much less visible at low sample counts.
+type BlueNoise struct {
+	base
+}
+
+func NewBlueNoise() *BlueNoise {
+	return &BlueNoise{newBase(0)}
+}
+
+func (s *BlueNoise) Get1D() float64 {
+	d := s.nextDim()
+	v := toUnit(nestedUniformScramble(sobol0(uint32(s.index)), uint32(concrand.Derive(s.seed, uint64(d), 1))))
+	return frac(v + s.shift(d, 0))
+}
+
+func (s *BlueNoise) Get2D() (float64, float64) {
+	d := s.nextDim()
+	i := uint32(s.index)
+	u := toUnit(nestedUniformScramble(sobol0(i), uint32(concrand.Derive(s.seed, uint64(d), 1))))
+	v := toUnit(nestedUniformScramble(sobol1(i), uint32(concrand.Derive(s.seed, uint64(d), 2))))
+	return frac(u + s.shift(d, 0)), frac(v + s.shift(d, 1))
+}
+
+func (s *BlueNoise) Clone(seed uint64) Sampler {
+	return &BlueNoise{newBase(seed)}
+}
+
+// shift reads the mask at an offset that depends on the dimension, so the
+// dimensions of a pixel are not rotated alike.
+func (s *BlueNoise) shift(dim, component int) float64 {
+	h := concrand.Derive(s.seed, uint64(dim), uint64(component), 3)
+	mask := blueNoiseMask()
+	x := (s.x + int(h&0xffff)) % blueNoiseSize
+	y := (s.y + int(h>>16&0xffff)) % blueNoiseSize
+	return mask[y*blueNoiseSize+x]
+}
+
+const blueNoiseSize = 64
+
+var (
+	blueNoiseOnce sync.Once
+	blueNoise     []float64
+)
+
+func blueNoiseMask() []float64 {
+	blueNoiseOnce.Do(func() {
+		blueNoise = voidAndCluster(blueNoiseSize, 1.5, 1)
+	})
+	return blueNoise
+}
+
+// voidAndCluster builds a size x size tileable dither array with Ulichney's
+// void and cluster method and returns the rank of every texel mapped to [0, 1).
+func voidAndCluster(size int, sigma float64, seed uint64) []float64 {
+	n := size * size
+
+	// Toroidal gaussian energy of a single point, indexed by offset.
+	kernel := make([]float64, n)
+	for dy := 0; dy < size; dy++ {
+		for dx := 0; dx < size; dx++ {
+			wx := float64(min(dx, size-dx))
+			wy := float64(min(dy, size-dy))
+			kernel[dy*size+dx] = math.Exp(-(wx*wx + wy*wy) / (2 * sigma * sigma))
+		}
+	}
+
+	pattern := make([]bool, n)
+	energy := make([]float64, n)
+	splat := func(p int, sign float64) {
+		px, py := p%size, p/size
+		for y := 0; y < size; y++ {
+			row := ((y - py + size) % size) * size
+			for x := 0; x < size; x++ {
+				energy[y*size+x] += sign * kernel[row+(x-px+size)%size]
+			}
+		}
+	}
+	// tightest returns the set texel with the highest energy, largest the
+	// free texel with the lowest one.
+	tightest := func(set bool) int {
+		best := -1
+		for i, e := range energy {
+			if pattern[i] == set && (best < 0 || e > energy[best]) {
+				best = i
+			}
+		}
+		return best
+	}
+	largest := func() int {
+		best := -1
+		for i, e := range energy {
+			if !pattern[i] && (best < 0 || e < energy[best]) {
+				best = i
+			}
+		}
+		return best
+	}
+
+	// Random initial pattern, relaxed until moving the tightest cluster into
+	// the largest void does not change anything.
+	rng := concrand.New(seed)
+	ones := n / 10
+	for placed := 0; placed < ones; {
+		p := rng.Intn(n)
+		if !pattern[p] {
+			pattern[p] = true
+			splat(p, 1)
+			placed++
+		}
+	}
+	for {
+		cluster := tightest(true)
+		pattern[cluster] = false
+		splat(cluster, -1)
+		void := largest()
+		pattern[void] = true
+		splat(void, 1)
+		if void == cluster {
+			break
+		}
+	}
+	initial := append([]bool(nil), pattern...)
+	initialEnergy := append([]float64(nil), energy...)
+
+	rank := make([]int, n)
+	// Phase 1: rank the initial points by removing the tightest clusters.
+	for r := ones - 1; r >= 0; r-- {
+		cluster := tightest(true)
+		pattern[cluster] = false
+		splat(cluster, -1)
+		rank[cluster] = r
+	}
+	// Phase 2: fill the largest voids up to half of the texels.
+	copy(pattern, initial)
+	copy(energy, initialEnergy)
+	r := ones
+	for ; r < n/2; r++ {
+		void := largest()
+		pattern[void] = true
+		splat(void, 1)
+		rank[void] = r
+	}
+	// Phase 3: the free texels are the minority now, fill their tightest
+	// clusters, measured by the energy of the free texels themselves.
+	for i := range energy {
+		energy[i] = 0
+	}
+	for i, set := range pattern {
+		if !set {
+			splat(i, 1)
+		}
+	}
+	for ; r < n; r++ {
+		cluster := tightest(false)
+		pattern[cluster] = true
+		splat(cluster, -1)
+		rank[cluster] = r
+	}
+
+	mask := make([]float64, n)
+	for i, r := range rank {
+		mask[i] = (float64(r) + 0.5) / float64(n)
+	}
+	return mask
+}
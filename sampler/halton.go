@@ -0,0 +1,48 @@
+package sampler
+
+// Halton uses the radical inverse in the n-th prime base for dimension n,
+// rotated by a random offset per pixel (Cranley-Patterson rotation) so that
+// pixels do not share the same pattern. Dimensions beyond the prime table are
+// drawn independently.
+type Halton struct {
+	base
+}
+
+var primes = [...]uint64{
+	2, 3, 5, 7, 11, 13, 17, 19, 23, 29, 31, 37, 41, 43, 47, 53,
+	59, 61, 67, 71, 73, 79, 83, 89, 97, 101, 103, 107, 109, 113, 127, 131,
+}
+
+func NewHalton() *Halton {
+	return &Halton{newBase(0)}
+}
+
+func (s *Halton) Get1D() float64 {
+	d := s.nextDim()
+	if d >= len(primes) {
+		return s.rng.Float64()
+	}
+	return frac(radicalInverse(primes[d], uint64(s.index)) + toUnit64(s.pixelHash(d, 0)))
+}
+
+func (s *Halton) Get2D() (float64, float64) {
+	return s.Get1D(), s.Get1D()
+}
+
+func (s *Halton) Clone(seed uint64) Sampler {
+	return &Halton{newBase(seed)}
+}
+
+// radicalInverse mirrors the digits of n in the given base around the decimal point.
+func radicalInverse(base, n uint64) float64 {
+	invBase := 1 / float64(base)
+	invBaseM := 1.0
+	var reversed uint64
+	for n > 0 {
+		next := n / base
+		reversed = reversed*base + (n - next*base)
+		invBaseM *= invBase
+		n = next
+	}
+	return min(float64(reversed)*invBaseM, oneMinusEpsilon)
+}
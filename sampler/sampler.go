@@ -0,0 +1,97 @@
+// Package sampler provides the sample sequences the renderer draws its random
+// numbers from. Low discrepancy sequences spread the samples of a pixel more
+// evenly than independent random numbers, so noise drops at equal sample counts.
+package sampler
+
+import (
+	"ray_tracing/concrand"
+
+	"golang.org/x/exp/rand"
+)
+
+// Sampler yields the numbers in [0, 1) of one pixel sample. Every Get1D or
+// Get2D call consumes the next dimension of the sequence, so callers must
+// draw in a consistent order (pixel, lens, time, then bounce after bounce).
+//
+// A Sampler is not safe for concurrent use, every goroutine gets its own Clone.
+type Sampler interface {
+	// StartPixelSample positions the sampler on the index-th sample of pixel (x, y).
+	StartPixelSample(x, y, index int)
+	Get1D() float64
+	Get2D() (float64, float64)
+	// Clone returns an independent sampler with the same settings whose
+	// sequences are derived from seed.
+	Clone(seed uint64) Sampler
+}
+
+// base keeps the pixel sample the sampler is positioned on, plus a random
+// stream for the dimensions a sequence does not cover.
+type base struct {
+	seed        uint64
+	x, y, index int
+	dim         int
+	rng         *rand.Rand
+}
+
+func newBase(seed uint64) base {
+	return base{seed: seed, rng: concrand.New(seed)}
+}
+
+func (b *base) StartPixelSample(x, y, index int) {
+	b.x, b.y, b.index = x, y, index
+	b.dim = 0
+	b.rng.Seed(concrand.Derive(b.seed, uint64(x), uint64(y), uint64(index)))
+}
+
+// nextDim returns the dimension the next draw belongs to.
+func (b *base) nextDim() int {
+	d := b.dim
+	b.dim++
+	return d
+}
+
+// pixelHash is constant over the samples of a pixel, so it can scramble or
+// permute a whole sequence consistently.
+func (b *base) pixelHash(dim int, tag uint64) uint64 {
+	return concrand.Derive(b.seed, uint64(b.x), uint64(b.y), uint64(dim), tag)
+}
+
+// Independent draws plain uniform random numbers, every sample of a pixel has its own stream.
+type Independent struct {
+	base
+}
+
+func NewIndependent() *Independent {
+	return &Independent{newBase(0)}
+}
+
+func (s *Independent) Get1D() float64 {
+	return s.rng.Float64()
+}
+
+func (s *Independent) Get2D() (float64, float64) {
+	return s.rng.Float64(), s.rng.Float64()
+}
+
+func (s *Independent) Clone(seed uint64) Sampler {
+	return &Independent{newBase(seed)}
+}
+
+// toUnit maps the bits of x to [0, 1), keeping below 1 after rounding.
+func toUnit(x uint32) float64 {
+	return min(float64(x)/(1<<32), oneMinusEpsilon)
+}
+
+// toUnit64 maps the top 53 bits of h to [0, 1).
+func toUnit64(h uint64) float64 {
+	return float64(h>>11) / (1 << 53)
+}
+
+const oneMinusEpsilon = 0x1.fffffffffffffp-1
+
+func frac(x float64) float64 {
+	if x >= 1 {
+		x -= 1
+	}
+	return min(x, oneMinusEpsilon)
+}
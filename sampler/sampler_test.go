@@ -0,0 +1,70 @@
+package sampler
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPermuteIsPermutation(t *testing.T) {
+	for _, l := range []uint32{1, 2, 7, 16, 100} {
+		seen := make([]bool, l)
+		for i := uint32(0); i < l; i++ {
+			p := permute(i, l, 0xdeadbeef)
+			if p >= l || seen[p] {
+				t.Fatalf("permute(%d, %d) = %d is out of range or repeated", i, l, p)
+			}
+			seen[p] = true
+		}
+	}
+}
+
+func TestBlueNoiseMaskRanks(t *testing.T) {
+	mask := blueNoiseMask()
+	seen := make(map[float64]bool, len(mask))
+	for _, v := range mask {
+		if v <= 0 || v >= 1 || seen[v] {
+			t.Fatalf("mask value %v is out of range or repeated", v)
+		}
+		seen[v] = true
+	}
+}
+
+// rmse estimates the area of the quarter unit disk with spp samples in each of
+// many pixels and returns the root mean square error over the pixels.
+func rmse(s Sampler, spp int) float64 {
+	const pixels = 256
+	s = s.Clone(7)
+	sum := 0.0
+	for p := 0; p < pixels; p++ {
+		hits := 0
+		for i := 0; i < spp; i++ {
+			s.StartPixelSample(p%16, p/16, i)
+			s.Get1D() // skip a dimension, as the camera would for the time
+			u, v := s.Get2D()
+			if u < 0 || u >= 1 || v < 0 || v >= 1 {
+				panic("sample out of [0, 1)")
+			}
+			if u*u+v*v < 1 {
+				hits++
+			}
+		}
+		e := float64(hits)/float64(spp) - math.Pi/4
+		sum += e * e
+	}
+	return math.Sqrt(sum / pixels)
+}
+
+func TestSamplersBeatIndependent(t *testing.T) {
+	const spp = 64
+	reference := rmse(NewIndependent(), spp)
+	for name, s := range map[string]Sampler{
+		"stratified": NewStratified(spp),
+		"halton":     NewHalton(),
+		"sobol":      NewSobol(),
+		"blue noise": NewBlueNoise(),
+	} {
+		if e := rmse(s, spp); e > 0.7*reference {
+			t.Errorf("%s: error %.4f, independent %.4f", name, e, reference)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package sampler
+
+import "math/bits"
+
+// Sobol draws every dimension pair from the first two Sobol dimensions, Owen
+// scrambled and with the sample index shuffled per pixel and dimension
+// (Burley, "Practical Hash-based Owen Scrambling"). Shuffling only permutes
+// indices within aligned power of two blocks, so every power of two prefix of
+// a pixel's samples is still well stratified.
+type Sobol struct {
+	base
+}
+
+func NewSobol() *Sobol {
+	return &Sobol{newBase(0)}
+}
+
+func (s *Sobol) Get1D() float64 {
+	d := s.nextDim()
+	i := nestedUniformScramble(uint32(s.index), uint32(s.pixelHash(d, 0)))
+	return toUnit(nestedUniformScramble(sobol0(i), uint32(s.pixelHash(d, 1))))
+}
+
+func (s *Sobol) Get2D() (float64, float64) {
+	d := s.nextDim()
+	i := nestedUniformScramble(uint32(s.index), uint32(s.pixelHash(d, 0)))
+	return toUnit(nestedUniformScramble(sobol0(i), uint32(s.pixelHash(d, 1)))),
+		toUnit(nestedUniformScramble(sobol1(i), uint32(s.pixelHash(d, 2))))
+}
+
+func (s *Sobol) Clone(seed uint64) Sampler {
+	return &Sobol{newBase(seed)}
+}
+
+// sobol0 is the first Sobol dimension, the base 2 van der Corput sequence.
+func sobol0(i uint32) uint32 {
+	return bits.Reverse32(i)
+}
+
+// sobol1 is the second Sobol dimension, its direction numbers follow v ^= v >> 1.
+func sobol1(i uint32) uint32 {
+	var v uint32 = 1 << 31
+	var r uint32
+	for ; i != 0; i >>= 1 {
+		if i&1 != 0 {
+			r ^= v
+		}
+		v ^= v >> 1
+	}
+	return r
+}
+
+// laineKarras is a hash that only lets bits affect higher bits, applied to a
+// bit reversed number it becomes an Owen scramble.
+func laineKarras(x, seed uint32) uint32 {
+	x += seed
+	x ^= x * 0x6c50b47c
+	x ^= x * 0xb82f1e52
+	x ^= x * 0xc7afe638
+	x ^= x * 0x8d22f6e6
+	return x
+}
+
+func nestedUniformScramble(x, seed uint32) uint32 {
+	return bits.Reverse32(laineKarras(bits.Reverse32(x), seed))
+}
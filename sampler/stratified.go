@@ -0,0 +1,77 @@
+package sampler
+
+import "math"
+
+// Stratified splits every dimension into as many strata as there are samples
+// per pixel and jitters one sample inside each. The strata are visited in an
+// order shuffled per pixel and dimension, samples past samplesPerPixel (time
+// budgets) fall back to independent numbers.
+type Stratified struct {
+	base
+	samplesPerPixel int
+}
+
+func NewStratified(samplesPerPixel int) *Stratified {
+	return &Stratified{base: newBase(0), samplesPerPixel: max(samplesPerPixel, 1)}
+}
+
+func (s *Stratified) Get1D() float64 {
+	d := s.nextDim()
+	if s.index >= s.samplesPerPixel {
+		return s.rng.Float64()
+	}
+	stratum := permute(uint32(s.index), uint32(s.samplesPerPixel), uint32(s.pixelHash(d, 0)))
+	return (float64(stratum) + s.rng.Float64()) / float64(s.samplesPerPixel)
+}
+
+func (s *Stratified) Get2D() (float64, float64) {
+	d := s.nextDim()
+	nx := int(math.Sqrt(float64(s.samplesPerPixel)))
+	ny := s.samplesPerPixel / nx
+	if s.index >= nx*ny {
+		return s.rng.Float64(), s.rng.Float64()
+	}
+	stratum := int(permute(uint32(s.index), uint32(nx*ny), uint32(s.pixelHash(d, 0))))
+	return (float64(stratum%nx) + s.rng.Float64()) / float64(nx),
+		(float64(stratum/nx) + s.rng.Float64()) / float64(ny)
+}
+
+func (s *Stratified) Clone(seed uint64) Sampler {
+	return &Stratified{base: newBase(seed), samplesPerPixel: s.samplesPerPixel}
+}
+
+// permute returns the position of i in a pseudo random permutation of [0, l)
+// picked by p, without building the permutation (Kensler, "Correlated
+// Multi-Jittered Sampling").
+func permute(i, l, p uint32) uint32 {
+	w := l - 1
+	w |= w >> 1
+	w |= w >> 2
+	w |= w >> 4
+	w |= w >> 8
+	w |= w >> 16
+	for {
+		i ^= p
+		i *= 0xe170893d
+		i ^= p >> 16
+		i ^= (i & w) >> 4
+		i ^= p >> 8
+		i *= 0x0929eb3f
+		i ^= p >> 23
+		i ^= (i & w) >> 1
+		i *= 1 | p>>27
+		i *= 0x6935fa69
+		i ^= (i & w) >> 11
+		i *= 0x74dcb303
+		i ^= (i & w) >> 2
+		i *= 0x9e501cc3
+		i ^= (i & w) >> 2
+		i *= 0xc860a3df
+		i &= w
+		i ^= i >> 5
+		if i < l {
+			break
+		}
+	}
+	return (i + p) % l
+}
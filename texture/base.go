@@ -9,6 +9,28 @@ type Texture interface {
 	Value(u, v float64, p vector.Point) vector.Color
 }
 
+// Footprint is how the texture coordinates and the point change from a pixel
+// to the next along X and Y of the image. The zero value is a point.
+type Footprint struct {
+	DuDx, DvDx, DuDy, DvDy float64
+	DpDx, DpDy             vector.Vector
+}
+
+// FilteredTexture is a Texture that can average itself over a footprint
+// instead of aliasing when it is smaller than a pixel.
+type FilteredTexture interface {
+	Texture
+	FilteredValue(u, v float64, p vector.Point, f *Footprint) vector.Color
+}
+
+// Filtered samples t over f if it can, and at the point otherwise.
+func Filtered(t Texture, u, v float64, p vector.Point, f *Footprint) vector.Color {
+	if ft, ok := t.(FilteredTexture); ok {
+		return ft.FilteredValue(u, v, p, f)
+	}
+	return t.Value(u, v, p)
+}
+
 type SolidColor struct {
 	vector.Color
 }
@@ -50,3 +72,29 @@ func (ct *CheckerTexture) Value(u, v float64, p vector.Point) vector.Color {
 		return ct.odd.Value(u, v, p)
 	}
 }
+
+// FilteredValue box filters the checker over the box spanned by the
+// footprint. The checker is the product of a square wave along each axis, so
+// the average is the product of their averages.
+func (ct *CheckerTexture) FilteredValue(u, v float64, p vector.Point, f *Footprint) vector.Color {
+	sign := 1.0
+	for axis := 0; axis < 3; axis++ {
+		width := ct.invScale * max(math.Abs(f.DpDx[axis]), math.Abs(f.DpDy[axis]))
+		sign *= squareWaveMean(ct.invScale*p[axis], width)
+	}
+	even := Filtered(ct.even, u, v, p, f).Multiply((1 + sign) / 2)
+	return even.Add(Filtered(ct.odd, u, v, p, f).Multiply((1 - sign) / 2))
+}
+
+// squareWaveMean averages (-1)^floor(x) over width around x.
+func squareWaveMean(x, width float64) float64 {
+	if width < 1e-9 {
+		return 1 - 2*math.Mod(math.Abs(math.Floor(x)), 2)
+	}
+	// The integral from 0 is a triangle wave between 0 and 1.
+	integral := func(x float64) float64 {
+		f := x - 2*math.Floor(x/2)
+		return min(f, 2-f)
+	}
+	return (integral(x+width/2) - integral(x-width/2)) / width
+}
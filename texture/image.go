@@ -0,0 +1,197 @@
+package texture
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"ray_tracing/framebuffer"
+	"ray_tracing/vector"
+	"strings"
+)
+
+// Filter selects how texels are combined between their centers.
+type Filter int
+
+const (
+	FilterBilinear Filter = iota // blend the four closest texels
+	FilterNearest                // take the closest texel
+)
+
+// Wrap selects what coordinates outside [0, 1] map to.
+type Wrap int
+
+const (
+	WrapRepeat Wrap = iota // tile the image
+	WrapClamp              // extend the edge texels
+	WrapMirror             // tile, flipping every other copy
+)
+
+// ImageTexture maps an image onto U, V. V runs from the bottom row up, as in
+// the coordinates of OBJ files. Texels are linear, 8 and 16 bit images are
+// converted from sRGB when loaded.
+type ImageTexture struct {
+	Filter Filter
+	Wrap   Wrap
+	// levels is the mipmap, each level half the size of the previous one down
+	// to a single texel. levels[0] is the image.
+	levels []*framebuffer.Framebuffer
+}
+
+func NewImageTexture(image *framebuffer.Framebuffer) *ImageTexture {
+	levels := []*framebuffer.Framebuffer{image}
+	for image.Width > 1 || image.Height > 1 {
+		image = downsample(image)
+		levels = append(levels, image)
+	}
+	return &ImageTexture{levels: levels}
+}
+
+// downsample box filters fb to half its size, rounded up. Odd sizes repeat
+// the last row or column.
+func downsample(fb *framebuffer.Framebuffer) *framebuffer.Framebuffer {
+	half := framebuffer.New((fb.Width+1)/2, (fb.Height+1)/2)
+	for y := 0; y < half.Height; y++ {
+		y0, y1 := 2*y, min(2*y+1, fb.Height-1)
+		for x := 0; x < half.Width; x++ {
+			x0, x1 := 2*x, min(2*x+1, fb.Width-1)
+			sum := fb.Color(x0, y0).Add(fb.Color(x1, y0)).Add(fb.Color(x0, y1)).Add(fb.Color(x1, y1))
+			half.SetColor(x, y, sum.Multiply(0.25))
+		}
+	}
+	return half
+}
+
+// LoadImageTexture reads a PNG, JPEG, PFM or Radiance HDR file, picked by extension.
+func LoadImageTexture(path string) (*ImageTexture, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("texture: %w", err)
+	}
+	defer f.Close()
+	return DecodeImageTexture(f, path)
+}
+
+// DecodeImageTexture reads the image in r, the extension of name selects the
+// format like in LoadImageTexture.
+func DecodeImageTexture(r io.Reader, name string) (*ImageTexture, error) {
+	var fb *framebuffer.Framebuffer
+	var err error
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".pfm":
+		fb, err = framebuffer.DecodePFM(r)
+	case ".hdr":
+		fb, err = framebuffer.DecodeHDR(r)
+	case ".png", ".jpg", ".jpeg":
+		var img image.Image
+		img, _, err = image.Decode(r)
+		if err == nil {
+			fb = linearize(img)
+		}
+	default:
+		return nil, fmt.Errorf("texture: %s: unsupported image format", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("texture: %s: %w", name, err)
+	}
+	return NewImageTexture(fb), nil
+}
+
+// linearize converts the sRGB pixels of img to linear colors, ignoring alpha.
+func linearize(img image.Image) *framebuffer.Framebuffer {
+	bounds := img.Bounds()
+	fb := framebuffer.New(bounds.Dx(), bounds.Dy())
+	for y := 0; y < fb.Height; y++ {
+		for x := 0; x < fb.Width; x++ {
+			c := color.NRGBA64Model.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.NRGBA64)
+			fb.SetColor(x, y, vector.Color{
+				srgbToLinear(float64(c.R) / 0xffff),
+				srgbToLinear(float64(c.G) / 0xffff),
+				srgbToLinear(float64(c.B) / 0xffff),
+			})
+		}
+	}
+	return fb
+}
+
+func srgbToLinear(c float64) float64 {
+	if c <= 0.04045 {
+		return c / 12.92
+	}
+	return math.Pow((c+0.055)/1.055, 2.4)
+}
+
+func (t *ImageTexture) Value(u, v float64, p vector.Point) vector.Color {
+	return t.sample(0, u, v)
+}
+
+// FilteredValue picks the mipmap levels whose texels are about as wide as the
+// footprint and blends between them.
+func (t *ImageTexture) FilteredValue(u, v float64, p vector.Point, f *Footprint) vector.Color {
+	image := t.levels[0]
+	width := max(
+		math.Abs(f.DuDx)*float64(image.Width), math.Abs(f.DvDx)*float64(image.Height),
+		math.Abs(f.DuDy)*float64(image.Width), math.Abs(f.DvDy)*float64(image.Height),
+	)
+	top := float64(len(t.levels) - 1)
+	level := min(max(math.Log2(width), 0), top)
+	if math.IsNaN(level) {
+		level = 0
+	}
+	if t.Filter == FilterNearest {
+		return t.sample(int(math.Round(level)), u, v)
+	}
+	l0 := math.Floor(level)
+	c := t.sample(int(l0), u, v)
+	if frac := level - l0; frac > 0 {
+		c = c.Multiply(1 - frac).Add(t.sample(int(l0)+1, u, v).Multiply(frac))
+	}
+	return c
+}
+
+// sample filters level within itself.
+func (t *ImageTexture) sample(level int, u, v float64) vector.Color {
+	image := t.levels[level]
+	if image.Width == 0 || image.Height == 0 {
+		// Cyan stands out as a missing texture.
+		return vector.Color{0, 1, 1}
+	}
+	// Texel centers sit at half integers.
+	x := u*float64(image.Width) - 0.5
+	y := (1-v)*float64(image.Height) - 0.5
+	if t.Filter == FilterNearest {
+		return t.texel(image, int(math.Round(x)), int(math.Round(y)))
+	}
+
+	x0, y0 := math.Floor(x), math.Floor(y)
+	fx, fy := x-x0, y-y0
+	i, j := int(x0), int(y0)
+	top := t.texel(image, i, j).Multiply(1 - fx).Add(t.texel(image, i+1, j).Multiply(fx))
+	bottom := t.texel(image, i, j+1).Multiply(1 - fx).Add(t.texel(image, i+1, j+1).Multiply(fx))
+	return top.Multiply(1 - fy).Add(bottom.Multiply(fy))
+}
+
+// texel returns the texel of image at column i and row j, wrapped into it.
+func (t *ImageTexture) texel(image *framebuffer.Framebuffer, i, j int) vector.Color {
+	return image.Color(wrap(i, image.Width, t.Wrap), wrap(j, image.Height, t.Wrap))
+}
+
+func wrap(i, n int, mode Wrap) int {
+	switch mode {
+	case WrapClamp:
+		return min(max(i, 0), n-1)
+	case WrapMirror:
+		i = ((i % (2 * n)) + 2*n) % (2 * n)
+		if i >= n {
+			i = 2*n - 1 - i
+		}
+		return i
+	default:
+		return ((i % n) + n) % n
+	}
+}
@@ -0,0 +1,152 @@
+package texture
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"ray_tracing/framebuffer"
+	"ray_tracing/vector"
+	"testing"
+)
+
+// gradient is a 4x2 image whose red channel counts columns and green rows.
+func gradient() *framebuffer.Framebuffer {
+	fb := framebuffer.New(4, 2)
+	for y := 0; y < fb.Height; y++ {
+		for x := 0; x < fb.Width; x++ {
+			fb.SetColor(x, y, vector.Color{float64(x), float64(y), 0.25})
+		}
+	}
+	return fb
+}
+
+func closeColor(a, b vector.Color) bool {
+	d := a.Add(b.Negative())
+	return d.IsCloseToZero()
+}
+
+func TestImageTextureSampling(t *testing.T) {
+	tex := NewImageTexture(gradient())
+	for _, test := range []struct {
+		filter Filter
+		wrap   Wrap
+		u, v   float64
+		want   vector.Color
+	}{
+		// Texel centers, v = 0.75 is the top row.
+		{FilterBilinear, WrapRepeat, 0.125, 0.75, vector.Color{0, 0, 0.25}},
+		{FilterBilinear, WrapRepeat, 0.625, 0.25, vector.Color{2, 1, 0.25}},
+		{FilterBilinear, WrapRepeat, 0.25, 0.5, vector.Color{0.5, 0.5, 0.25}},
+		{FilterNearest, WrapRepeat, 0.3, 0.6, vector.Color{1, 0, 0.25}},
+		// Left of the first column blends with the last one, or not.
+		{FilterBilinear, WrapRepeat, 0, 0.75, vector.Color{1.5, 0, 0.25}},
+		{FilterBilinear, WrapClamp, 0, 0.75, vector.Color{0, 0, 0.25}},
+		{FilterBilinear, WrapMirror, 0, 0.75, vector.Color{0, 0, 0.25}},
+		{FilterNearest, WrapRepeat, 1.125, 0.75, vector.Color{0, 0, 0.25}},
+		{FilterNearest, WrapClamp, 1.125, 0.75, vector.Color{3, 0, 0.25}},
+		{FilterNearest, WrapMirror, 1.125, 0.75, vector.Color{3, 0, 0.25}},
+		{FilterNearest, WrapMirror, -0.375, 0.75, vector.Color{1, 0, 0.25}},
+	} {
+		tex.Filter, tex.Wrap = test.filter, test.wrap
+		if got := tex.Value(test.u, test.v, vector.Point{}); !closeColor(got, test.want) {
+			t.Errorf("filter %d wrap %d at (%v, %v) = %v, want %v", test.filter, test.wrap, test.u, test.v, got, test.want)
+		}
+	}
+}
+
+func TestDecodeImageTexture(t *testing.T) {
+	hdr := gradient()
+	for _, encode := range []struct {
+		name string
+		f    framebuffer.Format
+		tol  float64
+	}{
+		{"t.pfm", framebuffer.FormatPFM, 0},
+		{"t.hdr", framebuffer.FormatHDR, 0.01},
+	} {
+		buf := bytes.Buffer{}
+		if err := framebuffer.Encode(&buf, hdr, encode.f); err != nil {
+			t.Fatal(err)
+		}
+		tex, err := DecodeImageTexture(&buf, encode.name)
+		if err != nil {
+			t.Fatalf("%s: %v", encode.name, err)
+		}
+		tex.Filter = FilterNearest
+		for y := 0; y < 2; y++ {
+			for x := 0; x < 4; x++ {
+				got := tex.Value((float64(x)+0.5)/4, 1-(float64(y)+0.5)/2, vector.Point{})
+				want := hdr.Color(x, y)
+				for c := range got {
+					if math.Abs(got[c]-want[c]) > encode.tol*math.Max(want[c], 1) {
+						t.Errorf("%s: texel %d, %d = %v, want %v", encode.name, x, y, got, want)
+						break
+					}
+				}
+			}
+		}
+	}
+
+	// 8 bit images are sRGB encoded.
+	img := image.NewNRGBA(image.Rect(0, 0, 1, 1))
+	img.Set(0, 0, color.NRGBA{255, 188, 0, 255})
+	buf := bytes.Buffer{}
+	png.Encode(&buf, img)
+	tex, err := DecodeImageTexture(&buf, "t.PNG")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := tex.Value(0.5, 0.5, vector.Point{}); math.Abs(got[0]-1) > 1e-6 || math.Abs(got[1]-0.5) > 0.005 || got[2] != 0 {
+		t.Errorf("sRGB (255, 188, 0) decoded to %v", got)
+	}
+
+	if _, err := DecodeImageTexture(&buf, "t.tga"); err == nil {
+		t.Error("decoded an unsupported format")
+	}
+}
+
+func TestImageTextureMipmap(t *testing.T) {
+	tex := NewImageTexture(gradient())
+	if len(tex.levels) != 3 || tex.levels[1].Width != 2 || tex.levels[1].Height != 1 || tex.levels[2].Width != 1 {
+		t.Fatalf("got %d levels", len(tex.levels))
+	}
+	// The last level is the mean of the image.
+	mean := vector.Color{1.5, 0.5, 0.25}
+	for _, f := range []Footprint{{DuDx: 1, DvDy: 1}, {DuDy: -100}} {
+		if got := tex.FilteredValue(0.3, 0.6, vector.Point{}, &f); !closeColor(got, mean) {
+			t.Errorf("footprint %+v gave %v, want %v", f, got, mean)
+		}
+	}
+	if got, want := tex.FilteredValue(0.3, 0.6, vector.Point{}, &Footprint{}), tex.Value(0.3, 0.6, vector.Point{}); got != want {
+		t.Errorf("a point footprint gave %v, want %v", got, want)
+	}
+	// Halfway between the first two levels, two texels wide along U at the
+	// middle of the top left quarter.
+	got := tex.FilteredValue(0.25, 0.75, vector.Point{}, &Footprint{DuDx: 1.5 / 4})
+	level0, level1 := tex.sample(0, 0.25, 0.75), tex.sample(1, 0.25, 0.75)
+	level := math.Log2(1.5)
+	if want := level0.Multiply(1 - level).Add(level1.Multiply(level)); !closeColor(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestCheckerFiltering(t *testing.T) {
+	ct := NewCheckerTexture(0.5, NewSolidColor(vector.Color{1, 1, 1}), NewSolidColor(vector.Color{0, 0, 0}))
+	for _, p := range []vector.Point{{0.1, 0.2, 0.3}, {-0.7, 0.2, 1.3}, {-0.1, -0.6, -2.2}} {
+		if got, want := ct.FilteredValue(0, 0, p, &Footprint{}), ct.Value(0, 0, p); got != want {
+			t.Errorf("a point footprint at %v gave %v, want %v", p, got, want)
+		}
+	}
+	// Whole periods along X, the other axes do not matter.
+	f := Footprint{DpDx: vector.Vector{3, 0, 0}}
+	if got := ct.FilteredValue(0, 0, vector.Point{0.3, 0.1, 0.2}, &f); !closeColor(got, vector.Color{0.5, 0.5, 0.5}) {
+		t.Errorf("got %v, want grey", got)
+	}
+	// A quarter of the box is in the black square.
+	f = Footprint{DpDy: vector.Vector{0.4, 0, 0}}
+	if got := ct.FilteredValue(0, 0, vector.Point{0.4, 0.1, 0.2}, &f); !closeColor(got, vector.Color{0.75, 0.75, 0.75}) {
+		t.Errorf("got %v, want 0.75", got)
+	}
+}
@@ -0,0 +1,152 @@
+package texture
+
+import (
+	"math"
+	"ray_tracing/concrand"
+	"ray_tracing/vector"
+)
+
+// Perlin is gradient noise over a lattice of pseudo random gradients, the
+// same seed always gives the same field.
+type Perlin struct {
+	perm [512]uint8
+}
+
+func NewPerlin(seed uint64) *Perlin {
+	n := &Perlin{}
+	for i, v := range concrand.New(seed).Perm(256) {
+		n.perm[i], n.perm[i+256] = uint8(v), uint8(v)
+	}
+	return n
+}
+
+// Noise returns the noise at p, in about [-1, 1] and 0 on lattice points.
+func (n *Perlin) Noise(p vector.Point) float64 {
+	fx, fy, fz := math.Floor(p[0]), math.Floor(p[1]), math.Floor(p[2])
+	// The lattice repeats every 256 cells.
+	x, y, z := int(fx)&255, int(fy)&255, int(fz)&255
+	dx, dy, dz := p[0]-fx, p[1]-fy, p[2]-fz
+	u, v, w := fade(dx), fade(dy), fade(dz)
+
+	hash := func(i, j, k int) int {
+		return int(n.perm[int(n.perm[int(n.perm[x+i])+y+j])+z+k])
+	}
+	corner := func(i, j, k int) float64 {
+		return perlinGradient(hash(i, j, k), dx-float64(i), dy-float64(j), dz-float64(k))
+	}
+	return lerp(w,
+		lerp(v, lerp(u, corner(0, 0, 0), corner(1, 0, 0)), lerp(u, corner(0, 1, 0), corner(1, 1, 0))),
+		lerp(v, lerp(u, corner(0, 0, 1), corner(1, 0, 1)), lerp(u, corner(0, 1, 1), corner(1, 1, 1))),
+	)
+}
+
+// FBM sums octaves of noise, each one lacunarity times finer and gain times
+// weaker than the previous one.
+func (n *Perlin) FBM(p vector.Point, octaves int, lacunarity, gain float64) float64 {
+	sum, weight := 0.0, 1.0
+	for i := 0; i < octaves; i++ {
+		sum += weight * n.Noise(p)
+		p = p.Multiply(lacunarity)
+		weight *= gain
+	}
+	return sum
+}
+
+// Turbulence is FBM of the absolute noise with the usual doubling
+// frequencies and halving weights, it is never negative.
+func (n *Perlin) Turbulence(p vector.Point, octaves int) float64 {
+	sum, weight := 0.0, 1.0
+	for i := 0; i < octaves; i++ {
+		sum += weight * math.Abs(n.Noise(p))
+		p = p.Multiply(2)
+		weight /= 2
+	}
+	return sum
+}
+
+// fade is the quintic 6t⁵ - 15t⁴ + 10t³, its first and second derivatives
+// vanish on the lattice so the noise has no creases there.
+func fade(t float64) float64 {
+	return t * t * t * (t*(t*6-15) + 10)
+}
+
+func lerp(t, a, b float64) float64 {
+	return a + t*(b-a)
+}
+
+// perlinGradient dots the offset with one of the 12 edge directions of a cube,
+// picked by the low bits of hash.
+func perlinGradient(hash int, x, y, z float64) float64 {
+	switch hash & 15 {
+	case 0, 12:
+		return x + y
+	case 1, 14:
+		return -x + y
+	case 2:
+		return x - y
+	case 3:
+		return -x - y
+	case 4:
+		return x + z
+	case 5:
+		return -x + z
+	case 6:
+		return x - z
+	case 7:
+		return -x - z
+	case 8:
+		return y + z
+	case 9, 13:
+		return -y + z
+	case 10:
+		return y - z
+	default:
+		return -y - z
+	}
+}
+
+// Worley is cellular noise: every unit cell holds one feature point at a
+// seeded random position, the noise is the distance to the closest ones.
+type Worley struct {
+	seed uint64
+}
+
+func NewWorley(seed uint64) *Worley {
+	return &Worley{seed: seed}
+}
+
+// Distances returns the distances from p to the closest and second closest
+// feature points.
+func (n *Worley) Distances(p vector.Point) (f1, f2 float64) {
+	f1, f2 = math.Inf(1), math.Inf(1)
+	cx, cy, cz := math.Floor(p[0]), math.Floor(p[1]), math.Floor(p[2])
+	// Feature points stay in their cell, the closest two are in the 27
+	// cells around p.
+	for i := -1.0; i <= 1; i++ {
+		for j := -1.0; j <= 1; j++ {
+			for k := -1.0; k <= 1; k++ {
+				cell := vector.Point{cx + i, cy + j, cz + k}
+				d := cell.Add(n.feature(cell)).Add(p.Negative()).Length()
+				if d < f1 {
+					f1, f2 = d, f1
+				} else if d < f2 {
+					f2 = d
+				}
+			}
+		}
+	}
+	return f1, f2
+}
+
+// feature returns the offset of the feature point of cell.
+func (n *Worley) feature(cell vector.Point) vector.Vector {
+	h := concrand.Derive(n.seed, uint64(int64(cell[0])), uint64(int64(cell[1])), uint64(int64(cell[2])))
+	// Three 21 bit fractions out of one hash.
+	const bits = 21
+	const mask = 1<<bits - 1
+	return vector.Vector{
+		float64(h&mask) / (mask + 1),
+		float64((h>>bits)&mask) / (mask + 1),
+		float64((h>>(2*bits))&mask) / (mask + 1),
+	}
+}
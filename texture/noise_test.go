@@ -0,0 +1,76 @@
+package texture
+
+import (
+	"math"
+	"ray_tracing/concrand"
+	"ray_tracing/vector"
+	"testing"
+)
+
+func TestPerlin(t *testing.T) {
+	a, b, other := NewPerlin(7), NewPerlin(7), NewPerlin(8)
+	rng := concrand.New(1)
+	differ := false
+	for i := 0; i < 1000; i++ {
+		p := vector.Point{rng.Float64()*100 - 50, rng.Float64()*100 - 50, rng.Float64()*100 - 50}
+		n := a.Noise(p)
+		if n != b.Noise(p) {
+			t.Fatalf("same seed gave %v and %v at %v", n, b.Noise(p), p)
+		}
+		differ = differ || n != other.Noise(p)
+		if math.Abs(n) > 1.1 {
+			t.Errorf("noise %v at %v", n, p)
+		}
+		// Continuous, the gradients are at most a few units long.
+		if d := math.Abs(a.Noise(p.Add(vector.Vector{1e-6, 0, 0})) - n); d > 1e-5 {
+			t.Errorf("noise jumps by %v at %v", d, p)
+		}
+		if a.Turbulence(p, 5) < 0 {
+			t.Errorf("negative turbulence at %v", p)
+		}
+	}
+	if !differ {
+		t.Error("seeds 7 and 8 gave the same noise")
+	}
+	if n := a.Noise(vector.Point{3, -4, 12}); n != 0 {
+		t.Errorf("noise %v on a lattice point", n)
+	}
+}
+
+func TestWorley(t *testing.T) {
+	w := NewWorley(3)
+	rng := concrand.New(2)
+	for i := 0; i < 1000; i++ {
+		p := vector.Point{rng.Float64()*20 - 10, rng.Float64()*20 - 10, rng.Float64()*20 - 10}
+		f1, f2 := w.Distances(p)
+		if f1 > f2 || f1 > math.Sqrt(3) {
+			t.Errorf("distances %v, %v at %v", f1, f2, p)
+		}
+	}
+	cell := vector.Point{-2, 5, 0}
+	if f1, _ := w.Distances(cell.Add(w.feature(cell))); f1 != 0 {
+		t.Errorf("distance %v on a feature point", f1)
+	}
+}
+
+func TestColorRamp(t *testing.T) {
+	ramp := ColorRamp{
+		{0.2, vector.Color{1, 0, 0}},
+		{0.6, vector.Color{0, 1, 0}},
+		{1, vector.Color{0, 0, 1}},
+	}
+	for _, test := range []struct {
+		t    float64
+		want vector.Color
+	}{
+		{-1, vector.Color{1, 0, 0}},
+		{0.2, vector.Color{1, 0, 0}},
+		{0.4, vector.Color{0.5, 0.5, 0}},
+		{0.9, vector.Color{0, 0.25, 0.75}},
+		{2, vector.Color{0, 0, 1}},
+	} {
+		if got := ramp.At(test.t); !closeColor(got, test.want) {
+			t.Errorf("At(%v) = %v, want %v", test.t, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package texture
+
+import (
+	"math"
+	"ray_tracing/vector"
+	"sort"
+)
+
+// ColorStop places a color at Position on a ColorRamp.
+type ColorStop struct {
+	Position float64
+	Color    vector.Color
+}
+
+// ColorRamp maps [0, 1] to colors by blending between stops sorted by position.
+type ColorRamp []ColorStop
+
+// At returns the color at t, the end stops extend beyond the first and last positions.
+func (r ColorRamp) At(t float64) vector.Color {
+	if len(r) == 0 {
+		return vector.Color{}
+	}
+	i := sort.Search(len(r), func(i int) bool { return r[i].Position > t })
+	switch i {
+	case 0:
+		return r[0].Color
+	case len(r):
+		return r[len(r)-1].Color
+	}
+	a, b := r[i-1], r[i]
+	f := (t - a.Position) / (b.Position - a.Position)
+	return a.Color.Multiply(1 - f).Add(b.Color.Multiply(f))
+}
+
+// MarbleTexture has veins where a sine along X is bent by turbulence. Scale
+// is the frequency of the veins, Turbulence how far they wander.
+type MarbleTexture struct {
+	Scale      float64
+	Turbulence float64
+	Octaves    int
+	Ramp       ColorRamp
+	noise      *Perlin
+}
+
+func NewMarbleTexture(seed uint64, scale float64) *MarbleTexture {
+	return &MarbleTexture{
+		Scale:      scale,
+		Turbulence: 10,
+		Octaves:    7,
+		Ramp: ColorRamp{
+			{0, vector.Color{0.25, 0.25, 0.3}},
+			{0.4, vector.Color{0.75, 0.75, 0.75}},
+			{1, vector.Color{0.95, 0.95, 0.92}},
+		},
+		noise: NewPerlin(seed),
+	}
+}
+
+func (m *MarbleTexture) Value(u, v float64, p vector.Point) vector.Color {
+	t := 0.5 * (1 + math.Sin(m.Scale*p[0]+m.Turbulence*m.noise.Turbulence(p, m.Octaves)))
+	return m.Ramp.At(t)
+}
+
+// WoodTexture has rings around the Y axis, distorted by noise so they are not
+// perfect circles. Scale is how many rings fit in a unit, Grain how many rings
+// fBm bends them by.
+type WoodTexture struct {
+	Scale float64
+	Grain float64
+	Ramp  ColorRamp
+	noise *Perlin
+}
+
+func NewWoodTexture(seed uint64, scale float64) *WoodTexture {
+	return &WoodTexture{
+		Scale: scale,
+		Grain: 0.4,
+		Ramp: ColorRamp{
+			{0, vector.Color{0.45, 0.25, 0.1}},
+			{0.6, vector.Color{0.65, 0.42, 0.2}},
+			{1, vector.Color{0.35, 0.18, 0.07}},
+		},
+		noise: NewPerlin(seed),
+	}
+}
+
+func (w *WoodTexture) Value(u, v float64, p vector.Point) vector.Color {
+	r := w.Scale*math.Hypot(p[0], p[2]) + w.Grain*w.noise.FBM(p.Multiply(2), 4, 2, 0.5)
+	return w.Ramp.At(r - math.Floor(r))
+}
+
+// GraniteTexture is a mix of cells and speckles: the Worley border distance
+// F2 - F1 picks the grain, fBm shades it. Scale is the grain frequency.
+type GraniteTexture struct {
+	Scale   float64
+	Ramp    ColorRamp
+	cells   *Worley
+	speckle *Perlin
+}
+
+func NewGraniteTexture(seed uint64, scale float64) *GraniteTexture {
+	return &GraniteTexture{
+		Scale: scale,
+		Ramp: ColorRamp{
+			{0, vector.Color{0.05, 0.05, 0.05}},
+			{0.35, vector.Color{0.35, 0.3, 0.3}},
+			{0.7, vector.Color{0.7, 0.62, 0.6}},
+			{1, vector.Color{0.9, 0.88, 0.85}},
+		},
+		cells:   NewWorley(seed),
+		speckle: NewPerlin(seed + 1),
+	}
+}
+
+func (g *GraniteTexture) Value(u, v float64, p vector.Point) vector.Color {
+	p = p.Multiply(g.Scale)
+	f1, f2 := g.cells.Distances(p)
+	t := min(f2-f1, 1) + 0.3*g.speckle.FBM(p.Multiply(4), 3, 2, 0.5)
+	return g.Ramp.At(t)
+}
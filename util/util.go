@@ -11,3 +11,8 @@ func DegressToRadians(degrees float64) float64 {
 func LinearToGamma(linearComponent float64) float64 {
 	return math.Sqrt(linearComponent)
 }
+
+// GammaToLinear undoes LinearToGamma.
+func GammaToLinear(gammaComponent float64) float64 {
+	return gammaComponent * gammaComponent
+}
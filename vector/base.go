@@ -44,7 +44,7 @@ func (v Vector) Length() float64 {
 }
 
 func (v *Vector) IsCloseToZero() bool {
-	return v[0] < 1e-8 && v[1] < 1e-8 && v[2] < 1e-8
+	return math.Abs(v[0]) < 1e-8 && math.Abs(v[1]) < 1e-8 && math.Abs(v[2]) < 1e-8
 }
 
 func (v Vector) String() string {
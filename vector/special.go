@@ -2,11 +2,8 @@ package vector
 
 import (
 	"math"
-	"time"
 
 	"golang.org/x/exp/rand"
-
-	"gonum.org/v1/gonum/mathext/prng"
 )
 
 func UnitVector(v Vector) Vector {
@@ -14,36 +11,37 @@ func UnitVector(v Vector) Vector {
 
 }
 
-var r *rand.Rand = rand.New(prng.NewSplitMix64(uint64(time.Now().UnixNano())))
+// The random helpers below draw from the stream they are given, which must
+// not be shared between goroutines (see package concrand).
 
-func Random() Vector {
+func Random(rng *rand.Rand) Vector {
 	return Vector{
-		r.Float64(),
-		r.Float64(),
-		r.Float64(),
+		rng.Float64(),
+		rng.Float64(),
+		rng.Float64(),
 	}
 }
 
-func RandomBounded(min, max float64) Vector {
+func RandomBounded(rng *rand.Rand, min, max float64) Vector {
 	return Vector{
-		min + r.Float64()*(max-min),
-		min + r.Float64()*(max-min),
-		min + r.Float64()*(max-min),
+		min + rng.Float64()*(max-min),
+		min + rng.Float64()*(max-min),
+		min + rng.Float64()*(max-min),
 	}
 }
 
-func RandomInUnitSphere() Vector {
+func RandomInUnitSphere(rng *rand.Rand) Vector {
 	for {
-		v := RandomBounded(-1, 1)
+		v := RandomBounded(rng, -1, 1)
 		if v.LengthSquared() < 1 {
 			return v
 		}
 	}
 }
 
-func RandomInUnitDisk() Vector {
+func RandomInUnitDisk(rng *rand.Rand) Vector {
 	for {
-		v := RandomBounded(-1, 1)
+		v := RandomBounded(rng, -1, 1)
 		v[2] = 0
 		if v.LengthSquared() < 1 {
 			return v
@@ -51,12 +49,12 @@ func RandomInUnitDisk() Vector {
 	}
 }
 
-func RandomUnitVector() Vector {
-	return UnitVector(RandomInUnitSphere())
+func RandomUnitVector(rng *rand.Rand) Vector {
+	return UnitVector(RandomInUnitSphere(rng))
 }
 
-func RandomOnHemisphere(normal Vector) Vector {
-	ushp := RandomUnitVector()
+func RandomOnHemisphere(rng *rand.Rand, normal Vector) Vector {
+	ushp := RandomUnitVector(rng)
 	if Dot(ushp, normal) > 0.0 {
 		return ushp
 	} else {
@@ -64,6 +62,43 @@ func RandomOnHemisphere(normal Vector) Vector {
 	}
 }
 
+// The Sample helpers below warp uniform numbers in [0, 1), as drawn from a
+// sampler.Sampler, into the same distributions as their Random counterparts.
+
+// SampleUnitDisk maps to a point in the unit disk (z = 0) with the concentric
+// mapping, which keeps strata of the square compact on the disk.
+func SampleUnitDisk(u, v float64) Vector {
+	a, b := 2*u-1, 2*v-1
+	if a == 0 && b == 0 {
+		return Vector{0, 0, 0}
+	}
+	var r, phi float64
+	if math.Abs(a) > math.Abs(b) {
+		r, phi = a, math.Pi/4*(b/a)
+	} else {
+		r, phi = b, math.Pi/2-math.Pi/4*(a/b)
+	}
+	return Vector{r * math.Cos(phi), r * math.Sin(phi), 0}
+}
+
+// SampleUnitVector maps to a direction uniformly distributed over the sphere.
+func SampleUnitVector(u, v float64) Vector {
+	z := 1 - 2*u
+	r := math.Sqrt(math.Max(0, 1-z*z))
+	phi := 2 * math.Pi * v
+	return Vector{r * math.Cos(phi), r * math.Sin(phi), z}
+}
+
+// OrthonormalBasis returns two unit vectors perpendicular to the unit vector n
+// and to each other (Duff et al., "Building an Orthonormal Basis, Revisited").
+func OrthonormalBasis(n Vector) (Vector, Vector) {
+	sign := math.Copysign(1, n[2])
+	a := -1 / (sign + n[2])
+	b := n[0] * n[1] * a
+	return Vector{1 + sign*n[0]*n[0]*a, sign * b, -sign * n[0]},
+		Vector{b, sign + n[1]*n[1]*a, -n[1]}
+}
+
 func Reflect(v, n Vector) Vector {
 	return v.Add(n.Multiply(Dot(v, n) * 2).Negative())
 }
@@ -0,0 +1,197 @@
+package vector
+
+import (
+	"errors"
+	"math"
+	"ray_tracing/util"
+)
+
+// Matrix4 is a row major 4x4 matrix, applied to column vectors.
+type Matrix4 [4][4]float64
+
+func Identity() Matrix4 {
+	return Matrix4{
+		{1, 0, 0, 0},
+		{0, 1, 0, 0},
+		{0, 0, 1, 0},
+		{0, 0, 0, 1},
+	}
+}
+
+// Multiply returns m·n, which applies n first.
+func (m Matrix4) Multiply(n Matrix4) Matrix4 {
+	var r Matrix4
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 4; j++ {
+			for k := 0; k < 4; k++ {
+				r[i][j] += m[i][k] * n[k][j]
+			}
+		}
+	}
+	return r
+}
+
+func (m Matrix4) Transpose() Matrix4 {
+	var r Matrix4
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 4; j++ {
+			r[i][j] = m[j][i]
+		}
+	}
+	return r
+}
+
+var ErrSingular = errors.New("vector: singular matrix")
+
+// Inverse uses Gauss-Jordan elimination with partial pivoting.
+func (m Matrix4) Inverse() (Matrix4, error) {
+	inv := Identity()
+	for col := 0; col < 4; col++ {
+		pivot := col
+		for row := col + 1; row < 4; row++ {
+			if math.Abs(m[row][col]) > math.Abs(m[pivot][col]) {
+				pivot = row
+			}
+		}
+		if m[pivot][col] == 0 {
+			return Matrix4{}, ErrSingular
+		}
+		m[col], m[pivot] = m[pivot], m[col]
+		inv[col], inv[pivot] = inv[pivot], inv[col]
+
+		scale := 1 / m[col][col]
+		for j := 0; j < 4; j++ {
+			m[col][j] *= scale
+			inv[col][j] *= scale
+		}
+		for row := 0; row < 4; row++ {
+			if row == col || m[row][col] == 0 {
+				continue
+			}
+			f := m[row][col]
+			for j := 0; j < 4; j++ {
+				m[row][j] -= f * m[col][j]
+				inv[row][j] -= f * inv[col][j]
+			}
+		}
+	}
+	return inv, nil
+}
+
+// Determinant3 is the determinant of the linear part, the upper left 3x3 block.
+func (m Matrix4) Determinant3() float64 {
+	return m[0][0]*(m[1][1]*m[2][2]-m[1][2]*m[2][1]) -
+		m[0][1]*(m[1][0]*m[2][2]-m[1][2]*m[2][0]) +
+		m[0][2]*(m[1][0]*m[2][1]-m[1][1]*m[2][0])
+}
+
+// Transform is an affine transform together with its inverse.
+type Transform struct {
+	M, Inv Matrix4
+}
+
+// NewTransform fails if m cannot be inverted.
+func NewTransform(m Matrix4) (Transform, error) {
+	inv, err := m.Inverse()
+	if err != nil {
+		return Transform{}, err
+	}
+	return Transform{M: m, Inv: inv}, nil
+}
+
+func IdentityTransform() Transform {
+	return Transform{M: Identity(), Inv: Identity()}
+}
+
+func Translate(v Vector) Transform {
+	m, inv := Identity(), Identity()
+	for i := 0; i < 3; i++ {
+		m[i][3] = v[i]
+		inv[i][3] = -v[i]
+	}
+	return Transform{M: m, Inv: inv}
+}
+
+// Scale by zero is not invertible and leaves Inv infinite.
+func Scale(x, y, z float64) Transform {
+	return Transform{
+		M: Matrix4{
+			{x, 0, 0, 0},
+			{0, y, 0, 0},
+			{0, 0, z, 0},
+			{0, 0, 0, 1},
+		},
+		Inv: Matrix4{
+			{1 / x, 0, 0, 0},
+			{0, 1 / y, 0, 0},
+			{0, 0, 1 / z, 0},
+			{0, 0, 0, 1},
+		},
+	}
+}
+
+// Rotate turns counter-clockwise by degrees around axis, looking down the axis
+// towards the origin.
+func Rotate(degrees float64, axis Vector) Transform {
+	a := UnitVector(axis)
+	sin, cos := math.Sincos(util.DegressToRadians(degrees))
+	m := Matrix4{
+		{a[0]*a[0] + (1-a[0]*a[0])*cos, a[0]*a[1]*(1-cos) - a[2]*sin, a[0]*a[2]*(1-cos) + a[1]*sin, 0},
+		{a[0]*a[1]*(1-cos) + a[2]*sin, a[1]*a[1] + (1-a[1]*a[1])*cos, a[1]*a[2]*(1-cos) - a[0]*sin, 0},
+		{a[0]*a[2]*(1-cos) - a[1]*sin, a[1]*a[2]*(1-cos) + a[0]*sin, a[2]*a[2] + (1-a[2]*a[2])*cos, 0},
+		{0, 0, 0, 1},
+	}
+	// Rotations are orthogonal.
+	return Transform{M: m, Inv: m.Transpose()}
+}
+
+func RotateX(degrees float64) Transform {
+	return Rotate(degrees, Vector{1, 0, 0})
+}
+
+func RotateY(degrees float64) Transform {
+	return Rotate(degrees, Vector{0, 1, 0})
+}
+
+func RotateZ(degrees float64) Transform {
+	return Rotate(degrees, Vector{0, 0, 1})
+}
+
+// Then returns the transform applying t first and next after it.
+func (t Transform) Then(next Transform) Transform {
+	return Transform{M: next.M.Multiply(t.M), Inv: t.Inv.Multiply(next.Inv)}
+}
+
+func (t Transform) Inverse() Transform {
+	return Transform{M: t.Inv, Inv: t.M}
+}
+
+func (t Transform) Point(p Point) Point {
+	m := &t.M
+	return Point{
+		m[0][0]*p[0] + m[0][1]*p[1] + m[0][2]*p[2] + m[0][3],
+		m[1][0]*p[0] + m[1][1]*p[1] + m[1][2]*p[2] + m[1][3],
+		m[2][0]*p[0] + m[2][1]*p[1] + m[2][2]*p[2] + m[2][3],
+	}
+}
+
+// Vector transforms a direction, which translations leave alone.
+func (t Transform) Vector(v Vector) Vector {
+	m := &t.M
+	return Vector{
+		m[0][0]*v[0] + m[0][1]*v[1] + m[0][2]*v[2],
+		m[1][0]*v[0] + m[1][1]*v[1] + m[1][2]*v[2],
+		m[2][0]*v[0] + m[2][1]*v[1] + m[2][2]*v[2],
+	}
+}
+
+// Normal transforms a surface normal with the inverse transpose, so it stays
+// perpendicular to the transformed surface. The result is not normalized.
+func (t Transform) Normal(n Vector) Vector {
+	inv := &t.Inv
+	return Vector{
+		inv[0][0]*n[0] + inv[1][0]*n[1] + inv[2][0]*n[2],
+		inv[0][1]*n[0] + inv[1][1]*n[1] + inv[2][1]*n[2],
+		inv[0][2]*n[0] + inv[1][2]*n[1] + inv[2][2]*n[2],
+	}
+}
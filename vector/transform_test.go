@@ -0,0 +1,45 @@
+package vector
+
+import (
+	"math"
+	"testing"
+)
+
+func closeTo(a, b Vector) bool {
+	d := a.Add(b.Negative())
+	return d.Length() < 1e-9
+}
+
+func TestTransform(t *testing.T) {
+	tr := Scale(2, 1, 0.5).Then(RotateZ(90)).Then(Translate(Vector{1, 2, 3}))
+	if got := tr.Point(Point{1, 1, 1}); !closeTo(got, Point{0, 4, 3.5}) {
+		t.Errorf("got %v, want (0 4 3.5)", got)
+	}
+	if got := tr.Vector(Vector{1, 0, 0}); !closeTo(got, Vector{0, 2, 0}) {
+		t.Errorf("got %v, want (0 2 0)", got)
+	}
+	if got := tr.Inverse().Point(tr.Point(Point{-3, 5, 7})); !closeTo(got, Point{-3, 5, 7}) {
+		t.Errorf("round trip gave %v", got)
+	}
+
+	// Normals stay perpendicular to transformed tangents.
+	n, tangent := Vector{1, 1, 0}, Vector{1, -1, 0}
+	if d := Dot(tr.Normal(n), tr.Vector(tangent)); math.Abs(d) > 1e-9 {
+		t.Errorf("transformed normal is off by %v", d)
+	}
+
+	inv, err := tr.M.Inverse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, row := range inv.Multiply(tr.M) {
+		for j := range row {
+			if math.Abs(row[j]-Identity()[i][j]) > 1e-12 {
+				t.Fatalf("M⁻¹M is %v", inv.Multiply(tr.M))
+			}
+		}
+	}
+	if _, err := Scale(1, 0, 1).M.Inverse(); err != ErrSingular {
+		t.Errorf("got %v inverting a flat scale", err)
+	}
+}
@@ -0,0 +1,153 @@
+package wavefront
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"ray_tracing/hittable"
+	"ray_tracing/texture"
+	"ray_tracing/vector"
+	"strings"
+)
+
+// Material is an entry of an MTL library, the statements the renderer has no
+// use for are skipped.
+type Material struct {
+	Name       string
+	Diffuse    vector.Color // Kd
+	Specular   vector.Color // Ks
+	Emission   vector.Color // Ke
+	Shininess  float64      // Ns
+	IOR        float64      // Ni
+	Dissolve   float64      // d, or 1 - Tr
+	Illum      int
+	Metallic   float64 // Pm
+	Roughness  float64 // Pr, negative when unset
+	DiffuseMap string  // map_Kd, relative to the library
+	// diffuseMap is the loaded DiffuseMap, it replaces Diffuse.
+	diffuseMap texture.Texture
+	// diffuseMapLine is the line of map_Kd, for errors loading it.
+	diffuseMapLine int
+}
+
+func newMaterial(name string) *Material {
+	return &Material{
+		Name:      name,
+		Diffuse:   vector.Color{0.8, 0.8, 0.8},
+		IOR:       1.5,
+		Dissolve:  1,
+		Illum:     2,
+		Roughness: -1,
+	}
+}
+
+// albedo is the diffuse map if loaded, otherwise the color c.
+func (m *Material) albedo(c vector.Color) texture.Texture {
+	if m.diffuseMap != nil {
+		return m.diffuseMap
+	}
+	return texture.NewSolidColor(c)
+}
+
+// roughness is Pr if given, otherwise it is derived from the Phong exponent.
+func (m *Material) roughness() float64 {
+	if m.Roughness >= 0 {
+		return m.Roughness
+	}
+	return math.Sqrt(2 / (m.Shininess + 2))
+}
+
+// Hittable converts the entry to the closest material of package hittable:
+// emissive entries become DiffuseLight, transparent ones Dielectric, metallic
+// ones (Pm, or the mirror illumination model 3) Metal, the rest Lambertian.
+// The diffuse map, if any, colors the Lambertian and Pm metals.
+func (m *Material) Hittable() hittable.Material {
+	switch {
+	case m.Emission != (vector.Color{}):
+		return hittable.NewDiffuseLight(m.Emission)
+	case m.Dissolve < 1 || m.Illum == 4 || m.Illum == 6 || m.Illum == 7 || m.Illum == 9:
+		return &hittable.Dielectric{IR: m.IOR}
+	case m.Metallic > 0:
+		return &hittable.Metal{Albedo: m.albedo(m.Diffuse), Fuzziness: min(m.roughness(), 1)}
+	case m.Illum == 3:
+		return hittable.NewMetal(m.Specular, min(m.roughness(), 1))
+	default:
+		return &hittable.Lambertian{Albedo: m.albedo(m.Diffuse)}
+	}
+}
+
+// parseMTL reads the library r, name is only used in errors.
+func parseMTL(r io.Reader, name string) (map[string]*Material, error) {
+	materials := map[string]*Material{}
+	var current *Material
+
+	scanner := newScanner(r)
+	line := 1
+	for ; scanner.Scan(); line++ {
+		fields := strings.Fields(stripComment(scanner.Text()))
+		if len(fields) == 0 {
+			continue
+		}
+		if fields[0] == "newmtl" {
+			if len(fields) < 2 {
+				return nil, errorf(name, line, "newmtl without a name")
+			}
+			current = newMaterial(strings.Join(fields[1:], " "))
+			materials[current.Name] = current
+			continue
+		}
+		if current == nil {
+			return nil, errorf(name, line, "%s before newmtl", fields[0])
+		}
+
+		var err error
+		switch fields[0] {
+		case "Kd":
+			current.Diffuse, err = parseColor(fields[1:])
+		case "Ks":
+			current.Specular, err = parseColor(fields[1:])
+		case "Ke":
+			current.Emission, err = parseColor(fields[1:])
+		case "Ns":
+			current.Shininess, err = parseFloat(fields[1:])
+			// Negative exponents make the derived roughness NaN.
+			if err == nil && !(current.Shininess >= 0) {
+				err = fmt.Errorf("negative exponent %v", current.Shininess)
+			}
+		case "Ni":
+			current.IOR, err = parseFloat(fields[1:])
+			if err == nil && !(current.IOR > 0) {
+				err = fmt.Errorf("index of refraction %v is not positive", current.IOR)
+			}
+		case "d":
+			current.Dissolve, err = parseFloat(fields[1:])
+		case "Tr":
+			var tr float64
+			tr, err = parseFloat(fields[1:])
+			current.Dissolve = 1 - tr
+		case "Pm":
+			current.Metallic, err = parseFloat(fields[1:])
+		case "Pr":
+			current.Roughness, err = parseFloat(fields[1:])
+		case "illum":
+			var illum float64
+			illum, err = parseFloat(fields[1:])
+			current.Illum = int(illum)
+		case "map_Kd":
+			if len(fields) < 2 {
+				err = fmt.Errorf("map_Kd without a file")
+			} else {
+				// Options such as -bm come before the file name, which is last.
+				current.DiffuseMap = fields[len(fields)-1]
+				current.diffuseMapLine = line
+			}
+		}
+		if err != nil {
+			return nil, errorf(name, line, "%s: %v", fields[0], err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, &ParseError{File: name, Line: line, Err: err}
+	}
+	return materials, nil
+}
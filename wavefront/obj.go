@@ -0,0 +1,443 @@
+// Package wavefront loads Wavefront OBJ meshes and their MTL material libraries.
+package wavefront
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"ray_tracing/hittable"
+	"ray_tracing/interval"
+	"ray_tracing/ray"
+	"ray_tracing/texture"
+	"ray_tracing/vector"
+	"strconv"
+	"strings"
+)
+
+// DefaultGroup holds the faces that come before the first g or o statement.
+const DefaultGroup = "default"
+
+// Model is a loaded OBJ file, it hits like the union of its groups.
+type Model struct {
+	// Groups holds a hierarchy over the triangles of every group.
+	Groups    map[string]hittable.Hittable
+	Materials map[string]*Material
+	mesh      hittable.Hittable
+}
+
+// Load reads the OBJ file at path, material libraries are looked up relative to it.
+func Load(path string) (*Model, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("wavefront: %w", err)
+	}
+	defer f.Close()
+
+	dir := filepath.Dir(path)
+	return parseOBJ(f, path, func(lib string) (io.ReadCloser, error) {
+		return os.Open(filepath.Join(dir, lib))
+	})
+}
+
+func (m *Model) Hit(r *ray.Ray, rayT interval.Interval, rec *hittable.HitRecord) bool {
+	return m.mesh.Hit(r, rayT, rec)
+}
+
+func (m *Model) BoundingBox() interval.AABB {
+	return m.mesh.BoundingBox()
+}
+
+// Unwrap lets hittable.NewLights find emissive faces.
+func (m *Model) Unwrap() hittable.Hittable {
+	return m.mesh
+}
+
+// vertex indexes the position, texture coordinate and normal of a face corner.
+// Missing texture coordinates and normals are -1.
+type vertex struct {
+	v, vt, vn int
+}
+
+type objParser struct {
+	name      string
+	openLib   func(string) (io.ReadCloser, error)
+	positions []vector.Point
+	uvs       [][2]float64
+	normals   []vector.Vector
+	materials map[string]*Material
+	material  hittable.Material
+	group     *group
+	groups    map[string]*group
+	order     []string
+	converted map[*Material]hittable.Material
+}
+
+// group collects the faces of a g or o statement into the arrays of a
+// hittable.TriangleMesh, with one vertex per distinct face corner.
+type group struct {
+	vertices      map[vertex]uint32
+	positions     []vector.Point
+	uvs           [][2]float64
+	normals       []vector.Vector
+	indices       []uint32
+	materials     []hittable.Material
+	faceMaterials []uint16
+	// materialIndex finds materials in materials, its keys are the pointers
+	// Material.Hittable returns.
+	materialIndex map[hittable.Material]int
+}
+
+var defaultMaterial = hittable.NewLambertian(vector.Color{0.8, 0.8, 0.8})
+
+// parseOBJ reads the OBJ file r, name is only used in errors. openLib opens
+// the material libraries it references.
+func parseOBJ(r io.Reader, name string, openLib func(string) (io.ReadCloser, error)) (*Model, error) {
+	p := &objParser{
+		name:      name,
+		openLib:   openLib,
+		materials: map[string]*Material{},
+		material:  defaultMaterial,
+		groups:    map[string]*group{},
+		converted: map[*Material]hittable.Material{},
+	}
+
+	scanner := newScanner(r)
+	line := 1
+	for ; scanner.Scan(); line++ {
+		fields := strings.Fields(stripComment(scanner.Text()))
+		if len(fields) == 0 {
+			continue
+		}
+		if err := p.statement(fields); err != nil {
+			// Errors in a material library point into the library.
+			var pe *ParseError
+			if errors.As(err, &pe) {
+				return nil, err
+			}
+			return nil, &ParseError{File: name, Line: line, Err: err}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, &ParseError{File: name, Line: line, Err: err}
+	}
+	return p.model()
+}
+
+func (p *objParser) statement(fields []string) error {
+	switch fields[0] {
+	case "v":
+		v, err := parseVector(fields[1:])
+		if err != nil {
+			return fmt.Errorf("v: %v", err)
+		}
+		p.positions = append(p.positions, v)
+	case "vt":
+		if len(fields) < 2 {
+			return fmt.Errorf("vt: want at least 1 value")
+		}
+		var uv [2]float64
+		for i := 0; i < 2 && i+1 < len(fields); i++ {
+			f, err := strconv.ParseFloat(fields[i+1], 64)
+			if err != nil {
+				return fmt.Errorf("vt: %v", err)
+			}
+			uv[i] = f
+		}
+		p.uvs = append(p.uvs, uv)
+	case "vn":
+		n, err := parseVector(fields[1:])
+		if err != nil {
+			return fmt.Errorf("vn: %v", err)
+		}
+		p.normals = append(p.normals, n)
+	case "f":
+		return p.face(fields[1:])
+	case "g", "o":
+		name := DefaultGroup
+		if len(fields) > 1 {
+			name = strings.Join(fields[1:], " ")
+		}
+		p.setGroup(name)
+	case "usemtl":
+		if len(fields) < 2 {
+			return fmt.Errorf("usemtl without a name")
+		}
+		m, ok := p.materials[strings.Join(fields[1:], " ")]
+		if !ok {
+			return fmt.Errorf("usemtl: unknown material %q", strings.Join(fields[1:], " "))
+		}
+		if p.converted[m] == nil {
+			p.converted[m] = m.Hittable()
+		}
+		p.material = p.converted[m]
+	case "mtllib":
+		for _, lib := range fields[1:] {
+			if err := p.loadLib(lib); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (p *objParser) loadLib(lib string) error {
+	f, err := p.openLib(lib)
+	if err != nil {
+		return fmt.Errorf("mtllib: %v", err)
+	}
+	defer f.Close()
+	materials, err := parseMTL(f, lib)
+	if err != nil {
+		return err
+	}
+	for name, m := range materials {
+		if m.DiffuseMap != "" {
+			if m.diffuseMap, err = p.loadTexture(path.Join(path.Dir(lib), m.DiffuseMap)); err != nil {
+				return &ParseError{File: lib, Line: m.diffuseMapLine, Err: fmt.Errorf("map_Kd: %w", err)}
+			}
+		}
+		p.materials[name] = m
+	}
+	return nil
+}
+
+// loadTexture opens images like libraries, relative to the OBJ file.
+func (p *objParser) loadTexture(name string) (texture.Texture, error) {
+	f, err := p.openLib(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return texture.DecodeImageTexture(f, name)
+}
+
+// face triangulates the polygon as a fan around its first corner.
+func (p *objParser) face(corners []string) error {
+	if len(corners) < 3 {
+		return fmt.Errorf("f: want at least 3 vertices, got %d", len(corners))
+	}
+	vertices := make([]vertex, len(corners))
+	for i, c := range corners {
+		v, err := p.vertex(c)
+		if err != nil {
+			return fmt.Errorf("f: %v", err)
+		}
+		vertices[i] = v
+	}
+	for i := 1; i+1 < len(vertices); i++ {
+		if err := p.triangle(vertices[0], vertices[i], vertices[i+1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *objParser) setGroup(name string) {
+	g, ok := p.groups[name]
+	if !ok {
+		g = &group{vertices: map[vertex]uint32{}, materialIndex: map[hittable.Material]int{}}
+		p.groups[name] = g
+		p.order = append(p.order, name)
+	}
+	p.group = g
+}
+
+func (p *objParser) triangle(a, b, c vertex) error {
+	if p.group == nil {
+		p.setGroup(DefaultGroup)
+	}
+	g := p.group
+	for _, v := range [3]vertex{a, b, c} {
+		g.indices = append(g.indices, p.meshVertex(g, v))
+	}
+
+	material, ok := g.materialIndex[p.material]
+	if !ok {
+		material = len(g.materials)
+		if material > math.MaxUint16 {
+			return fmt.Errorf("f: more than %d materials in one group", math.MaxUint16+1)
+		}
+		g.materials = append(g.materials, p.material)
+		g.materialIndex[p.material] = material
+	}
+	g.faceMaterials = append(g.faceMaterials, uint16(material))
+	return nil
+}
+
+// meshVertex returns the index of v in the arrays of g, adding it on first use.
+func (p *objParser) meshVertex(g *group, v vertex) uint32 {
+	if i, ok := g.vertices[v]; ok {
+		return i
+	}
+	i := uint32(len(g.positions))
+	g.vertices[v] = i
+	g.positions = append(g.positions, p.positions[v.v])
+	uv, n := [2]float64{}, vector.Vector{}
+	if v.vt >= 0 {
+		uv = p.uvs[v.vt]
+	}
+	if v.vn >= 0 {
+		n = p.normals[v.vn]
+	}
+	g.uvs = append(g.uvs, uv)
+	g.normals = append(g.normals, n)
+	return i
+}
+
+// mesh builds the group's TriangleMesh. Vertex attributes only some of the
+// faces have are dropped, partial ones would be garbage on the others.
+func (g *group) mesh() *hittable.TriangleMesh {
+	m := hittable.NewTriangleMesh(g.positions, g.indices, g.materials[0])
+	if len(g.materials) > 1 {
+		m.SetMaterials(g.materials, g.faceMaterials)
+	}
+	uvs, normals := true, true
+	for v := range g.vertices {
+		uvs = uvs && v.vt >= 0
+		normals = normals && v.vn >= 0
+	}
+	if uvs {
+		m.SetUVs(g.uvs)
+	}
+	if normals {
+		m.SetNormals(g.normals)
+	}
+	return m
+}
+
+// vertex parses a v, v/vt, v//vn or v/vt/vn face corner.
+func (p *objParser) vertex(s string) (vertex, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) > 3 {
+		return vertex{}, fmt.Errorf("malformed vertex %q", s)
+	}
+	v := vertex{-1, -1, -1}
+	var err error
+	if v.v, err = index(parts[0], len(p.positions)); err != nil {
+		return vertex{}, err
+	}
+	if len(parts) > 1 && parts[1] != "" {
+		if v.vt, err = index(parts[1], len(p.uvs)); err != nil {
+			return vertex{}, err
+		}
+	}
+	if len(parts) > 2 && parts[2] != "" {
+		if v.vn, err = index(parts[2], len(p.normals)); err != nil {
+			return vertex{}, err
+		}
+	}
+	return v, nil
+}
+
+// index turns the 1 based, or negative and relative to the end, OBJ index s
+// into a slice index below n.
+func index(s string, n int) (int, error) {
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, err
+	}
+	if i < 0 {
+		i += n
+	} else {
+		i--
+	}
+	if i < 0 || i >= n {
+		return 0, fmt.Errorf("index %s out of range, %d defined", s, n)
+	}
+	return i, nil
+}
+
+func (p *objParser) model() (*Model, error) {
+	if len(p.groups) == 0 {
+		return nil, fmt.Errorf("wavefront: %s: no faces", p.name)
+	}
+	m := &Model{Groups: map[string]hittable.Hittable{}, Materials: p.materials}
+	groups := make([]hittable.Hittable, 0, len(p.order))
+	for _, name := range p.order {
+		if len(p.groups[name].indices) == 0 {
+			continue
+		}
+		mesh := p.groups[name].mesh()
+		m.Groups[name] = mesh
+		groups = append(groups, mesh)
+	}
+	if len(groups) == 0 {
+		return nil, fmt.Errorf("wavefront: %s: no faces", p.name)
+	}
+	m.mesh = hittable.NewSAHTree(groups...)
+	return m, nil
+}
+
+func stripComment(line string) string {
+	if i := strings.IndexByte(line, '#'); i >= 0 {
+		return line[:i]
+	}
+	return line
+}
+
+// ParseError reports malformed input on a line of an OBJ or MTL file.
+type ParseError struct {
+	File string
+	Line int
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("wavefront: %s:%d: %v", e.File, e.Line, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// maxLineLength bounds the lines the scanners accept, the default of bufio
+// is too short for faces with many corners.
+const maxLineLength = 16 << 20
+
+func newScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxLineLength)
+	return scanner
+}
+
+func errorf(name string, line int, format string, args ...any) error {
+	return &ParseError{File: name, Line: line, Err: fmt.Errorf(format, args...)}
+}
+
+func parseFloat(fields []string) (float64, error) {
+	if len(fields) < 1 {
+		return 0, fmt.Errorf("want a value")
+	}
+	return strconv.ParseFloat(fields[0], 64)
+}
+
+// parseVector reads the first three fields, further ones (the w of v, or
+// vertex colors) are ignored.
+func parseVector(fields []string) (vector.Vector, error) {
+	if len(fields) < 3 {
+		return vector.Vector{}, fmt.Errorf("want 3 values, got %d", len(fields))
+	}
+	var v vector.Vector
+	for i := range v {
+		f, err := strconv.ParseFloat(fields[i], 64)
+		if err != nil {
+			return vector.Vector{}, err
+		}
+		v[i] = f
+	}
+	return v, nil
+}
+
+// parseColor reads r g b, a single value is gray.
+func parseColor(fields []string) (vector.Color, error) {
+	if len(fields) == 1 || len(fields) == 2 {
+		f, err := strconv.ParseFloat(fields[0], 64)
+		return vector.Color{f, f, f}, err
+	}
+	return parseVector(fields)
+}
@@ -0,0 +1,211 @@
+package wavefront
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"ray_tracing/framebuffer"
+	"ray_tracing/hittable"
+	"ray_tracing/interval"
+	"ray_tracing/ray"
+	"ray_tracing/vector"
+	"strings"
+	"testing"
+)
+
+const testMTL = `# materials
+newmtl white
+Kd 0.7 0.7 0.7
+
+newmtl lamp
+Ke 10 10 10
+
+newmtl glass
+Ni 1.33
+d 0.1
+
+newmtl steel
+Kd 0.5 0.5 0.6
+Pm 1
+Pr 0.2
+`
+
+const testOBJ = `mtllib test.mtl
+v 0 0 0
+v 1 0 0
+v 1 1 0
+v 0 1 0
+vt 0 0
+vt 1 0
+vt 1 1
+vt 0 1
+vn 0 0 1
+usemtl white
+g floor
+f 1/1/1 2/2/1 3/3/1 4/4/1
+g lamp
+usemtl lamp
+f -4//1 -3//1 -1//1
+`
+
+func openTestLib(lib string) (io.ReadCloser, error) {
+	if lib != "test.mtl" {
+		return nil, os.ErrNotExist
+	}
+	return io.NopCloser(strings.NewReader(testMTL)), nil
+}
+
+func TestParseOBJ(t *testing.T) {
+	m, err := parseOBJ(strings.NewReader(testOBJ), "test.obj", openTestLib)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Groups) != 2 || m.Groups["floor"] == nil || m.Groups["lamp"] == nil {
+		t.Fatalf("got groups %v", m.Groups)
+	}
+	if _, ok := m.Materials["glass"].Hittable().(*hittable.Dielectric); !ok {
+		t.Errorf("glass is %T", m.Materials["glass"].Hittable())
+	}
+	if metal, ok := m.Materials["steel"].Hittable().(*hittable.Metal); !ok || metal.Fuzziness != 0.2 {
+		t.Errorf("steel is %#v", m.Materials["steel"].Hittable())
+	}
+	if n := hittable.NewLights(m).Len(); n != 1 {
+		t.Errorf("found %d lights, want the lamp triangle", n)
+	}
+
+	// The quad was split into two triangles, the second one holds (0.75, 0.9).
+	r := &ray.Ray{Origin: vector.Point{0.75, 0.9, 1}, Direction: vector.Vector{0, 0, -1}}
+	rec := hittable.HitRecord{}
+	if !m.Groups["floor"].Hit(r, interval.Interval{0.001, math.Inf(1)}, &rec) {
+		t.Fatal("ray missed the floor")
+	}
+	if math.Abs(rec.U-0.75) > 1e-9 || math.Abs(rec.V-0.9) > 1e-9 {
+		t.Errorf("got UV %v %v, want 0.75 0.9", rec.U, rec.V)
+	}
+	if l, ok := rec.Material.(*hittable.Lambertian); !ok || l.Albedo.Value(0, 0, vector.Point{}) != (vector.Color{0.7, 0.7, 0.7}) {
+		t.Errorf("floor material is %#v", rec.Material)
+	}
+}
+
+func TestParseOBJErrors(t *testing.T) {
+	for _, test := range []struct {
+		obj, file string
+		line      int
+	}{
+		{"v 0 0 0\nv 1 0\n", "test.obj", 2},
+		{"v 0 0 0\nv 1 0 0\nv 0 1 0\n\nf 1 2 4\n", "test.obj", 5},
+		{"v 0 0 0\nf 1 1\n", "test.obj", 2},
+		{"usemtl nothing\n", "test.obj", 1},
+		{"mtllib missing.mtl\n", "test.obj", 1},
+		{"f 1/x/1 2 3\n", "test.obj", 1},
+	} {
+		_, err := parseOBJ(strings.NewReader(test.obj), "test.obj", openTestLib)
+		var pe *ParseError
+		if !errors.As(err, &pe) || pe.File != test.file || pe.Line != test.line {
+			t.Errorf("%q: got error %v, want one on %s:%d", test.obj, err, test.file, test.line)
+		}
+	}
+
+	for _, mtl := range []string{
+		"newmtl a\nKd 1 x 1\n",
+		"newmtl a\nNi 0\n",
+		"newmtl a\nNi -1.5\n",
+		"newmtl a\nNs -10\n",
+		"newmtl a\nNs nan\n",
+	} {
+		_, err := parseMTL(strings.NewReader(mtl), "bad.mtl")
+		var pe *ParseError
+		if !errors.As(err, &pe) || pe.File != "bad.mtl" || pe.Line != 2 {
+			t.Errorf("%q: got error %v, want one on bad.mtl:2", mtl, err)
+		}
+	}
+}
+
+func TestDiffuseMap(t *testing.T) {
+	fb := framebuffer.New(1, 1)
+	fb.SetColor(0, 0, vector.Color{0.5, 0.25, 0.125})
+	pfm := bytes.Buffer{}
+	framebuffer.EncodePFM(&pfm, fb)
+
+	// The map is relative to the library, which is in another directory.
+	files := map[string]string{
+		"materials/lib.mtl":            "newmtl brick\nmap_Kd -bm 1 textures/brick.pfm\n",
+		"materials/textures/brick.pfm": pfm.String(),
+	}
+	open := func(name string) (io.ReadCloser, error) {
+		f, ok := files[name]
+		if !ok {
+			return nil, os.ErrNotExist
+		}
+		return io.NopCloser(strings.NewReader(f)), nil
+	}
+	obj := "mtllib materials/lib.mtl\nv 0 0 0\nv 1 0 0\nv 0 1 0\nusemtl brick\nf 1 2 3\n"
+	m, err := parseOBJ(strings.NewReader(obj), "test.obj", open)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, ok := m.Materials["brick"].Hittable().(*hittable.Lambertian)
+	if !ok || l.Albedo.Value(0.5, 0.5, vector.Point{}) != (vector.Color{0.5, 0.25, 0.125}) {
+		t.Errorf("brick is %#v", m.Materials["brick"].Hittable())
+	}
+
+	delete(files, "materials/textures/brick.pfm")
+	_, err = parseOBJ(strings.NewReader(obj), "test.obj", open)
+	var pe *ParseError
+	if !errors.As(err, &pe) || pe.File != "materials/lib.mtl" || pe.Line != 2 || !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("got error %v, want a missing map at materials/lib.mtl:2", err)
+	}
+}
+
+func TestParseLongLines(t *testing.T) {
+	// A polygon with more corners than fit in bufio's default line length.
+	obj := strings.Builder{}
+	obj.WriteString("# " + strings.Repeat("comment ", 10000) + "\n")
+	corners := 20000
+	for i := 0; i < corners; i++ {
+		a := 2 * math.Pi * float64(i) / float64(corners)
+		fmt.Fprintf(&obj, "v %v %v 0\n", math.Cos(a), math.Sin(a))
+	}
+	obj.WriteString("f")
+	for i := 1; i <= corners; i++ {
+		fmt.Fprintf(&obj, " %d", i)
+	}
+	obj.WriteString("\n")
+	if _, err := parseOBJ(strings.NewReader(obj.String()), "long.obj", openTestLib); err != nil {
+		t.Fatal(err)
+	}
+
+	tooLong := "v 0 0 0\n# " + strings.Repeat("x", maxLineLength) + "\n"
+	_, err := parseOBJ(strings.NewReader(tooLong), "long.obj", openTestLib)
+	var pe *ParseError
+	if !errors.As(err, &pe) || pe.Line != 2 || !errors.Is(err, bufio.ErrTooLong) {
+		t.Errorf("got error %v, want a too long line at long.obj:2", err)
+	}
+	_, err = parseMTL(strings.NewReader("newmtl a\n"+tooLong), "long.mtl")
+	if !errors.As(err, &pe) || pe.File != "long.mtl" || pe.Line != 3 {
+		t.Errorf("got error %v, want a too long line at long.mtl:3", err)
+	}
+}
+
+func TestParseTooManyMaterials(t *testing.T) {
+	const n = math.MaxUint16 + 2
+	mtl := strings.Builder{}
+	obj := strings.Builder{}
+	obj.WriteString("mtllib many.mtl\nv 0 0 0\nv 1 0 0\nv 0 1 0\n")
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&mtl, "newmtl m%d\n", i)
+		fmt.Fprintf(&obj, "usemtl m%d\nf 1 2 3\n", i)
+	}
+	open := func(string) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(mtl.String())), nil
+	}
+	_, err := parseOBJ(strings.NewReader(obj.String()), "many.obj", open)
+	var pe *ParseError
+	if want := 4 + 2*n; !errors.As(err, &pe) || pe.Line != want {
+		t.Errorf("got error %v, want one at many.obj:%d", err, want)
+	}
+}